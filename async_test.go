@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestQueryAsync(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	cancel, done := pg.QueryAsync("SELECT 1;")
+	if err := <-done; err != nil {
+		t.Fatalf("QueryAsync: %v", err)
+	}
+	cancel()
+	if _, ok := <-done; ok {
+		t.Error("done not closed")
+	}
+
+	// Cancelled while waiting for another statement, the query never runs.
+	pg.mu.Lock()
+	cancel, done = pg.QueryAsync("CREATE TABLE never (n int);")
+	cancel()
+	pg.mu.Unlock()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled before running: got %v, want context.Canceled", err)
+	}
+	if err := pg.Query("SELECT * FROM never;"); err == nil {
+		t.Error("cancelled statement ran")
+	}
+
+	// Cancelled while running, the statement is interrupted and the
+	// instance closed.
+	start := time.Now()
+	cancel, done = pg.QueryAsync("SELECT count(*) FROM generate_series(1, 1000000000);")
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled while running: got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query was not interrupted, took %v", elapsed)
+	}
+	if err := pg.Query("SELECT 1;"); !errors.Is(err, ErrClosed) {
+		t.Errorf("query after cancel: got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// Type OIDs of the further types QueryBinary decodes.
+const (
+	byteaOID       = 17
+	nameOID        = 19
+	textOID        = 25
+	oidOID         = 26
+	jsonOID        = 114
+	bpcharOID      = 1042
+	varcharOID     = 1043
+	dateOID        = 1082
+	timestampOID   = 1114
+	timestamptzOID = 1184
+	numericOID     = 1700
+	uuidOID        = 2950
+	jsonbOID       = 3802
+)
+
+// BinaryResult holds the rows of a query fetched in binary format.
+type BinaryResult struct {
+	Columns []string
+	Types   []uint32 // type OID of each column
+	Rows    [][]any  // nil for NULL
+}
+
+// QueryBinary runs a single query through the frontend/backend protocol,
+// as Serve does for clients, asking for its results in binary format, and
+// decodes each value into an exact Go type:
+//
+//	bool                   bool
+//	int2, int4, int8       int64
+//	float4, float8         float64
+//	numeric                *big.Rat
+//	timestamp, date        time.Time in UTC
+//	timestamptz            time.Time in UTC, the instant stored
+//	bytea                  []byte
+//	text, varchar, name,
+//	bpchar, json, jsonb    string
+//	oid                    uint32
+//	uuid                   string, in the usual hyphenated form
+//
+// Values of other types are returned as their raw binary form, []byte.
+// Unlike the text results of QueryRows, numeric keeps every digit and
+// bytea needs no unescaping. Numeric NaN and infinities, and infinite
+// timestamps and dates, have no Go equivalent and are reported as errors.
+func (p *PGLite) QueryBinary(sql string) (*BinaryResult, error) {
+	// Parse and Bind the unnamed statement and portal, with no parameters
+	// and one result format code, binary, for all columns; then Describe
+	// the portal, Execute it for all rows and Sync.
+	var msgs []byte
+	msgs = appendMessage(msgs, 'P', []byte("\x00"+sql+"\x00\x00\x00"))
+	bind := []byte("\x00\x00")
+	bind = binary.BigEndian.AppendUint16(bind, 0)
+	bind = binary.BigEndian.AppendUint16(bind, 0)
+	bind = binary.BigEndian.AppendUint16(bind, 1)
+	bind = binary.BigEndian.AppendUint16(bind, 1)
+	msgs = appendMessage(msgs, 'B', bind)
+	msgs = appendMessage(msgs, 'D', []byte("P\x00"))
+	msgs = appendMessage(msgs, 'E', []byte("\x00\x00\x00\x00\x00"))
+	msgs = appendMessage(msgs, 'S', nil)
+
+	reply, err := p.roundTrip(msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BinaryResult{}
+	var qerr error
+	for len(reply) > 0 {
+		if len(reply) < 5 {
+			return nil, errors.New("query binary: truncated reply")
+		}
+		typ := reply[0]
+		n := int(binary.BigEndian.Uint32(reply[1:5]))
+		if n < 4 || len(reply) < 1+n {
+			return nil, errors.New("query binary: truncated reply")
+		}
+		body := reply[5 : 1+n]
+		reply = reply[1+n:]
+
+		switch typ {
+		case 'T': // RowDescription
+			if err := res.describe(body); err != nil {
+				return nil, err
+			}
+		case 'D': // DataRow
+			if qerr != nil {
+				continue
+			}
+			row, err := res.decodeRow(body)
+			if err != nil {
+				qerr = err
+				continue
+			}
+			res.Rows = append(res.Rows, row)
+		case 'E': // ErrorResponse
+			if qerr == nil {
+				qerr = parseErrorResponse(body)
+			}
+		}
+	}
+	if qerr != nil {
+		return nil, qerr
+	}
+	return res, nil
+}
+
+// describe reads the columns from a RowDescription message.
+func (r *BinaryResult) describe(body []byte) error {
+	if len(body) < 2 {
+		return errors.New("query binary: malformed row description")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	for i := 0; i < n; i++ {
+		end := bytes.IndexByte(body, 0)
+		if end < 0 || len(body) < end+1+18 {
+			return errors.New("query binary: malformed row description")
+		}
+		r.Columns = append(r.Columns, string(body[:end]))
+		// Table OID (4), column number (2), then the type OID.
+		r.Types = append(r.Types, binary.BigEndian.Uint32(body[end+1+6:]))
+		body = body[end+1+18:]
+	}
+	return nil
+}
+
+// decodeRow decodes the values in a DataRow message.
+func (r *BinaryResult) decodeRow(body []byte) ([]any, error) {
+	if len(body) < 2 || int(binary.BigEndian.Uint16(body)) != len(r.Types) {
+		return nil, errors.New("query binary: malformed data row")
+	}
+	body = body[2:]
+	row := make([]any, len(r.Types))
+	for i := range row {
+		if len(body) < 4 {
+			return nil, errors.New("query binary: malformed data row")
+		}
+		n := int32(binary.BigEndian.Uint32(body))
+		body = body[4:]
+		if n < 0 {
+			continue // NULL
+		}
+		if len(body) < int(n) {
+			return nil, errors.New("query binary: malformed data row")
+		}
+		v, err := decodeBinary(r.Types[i], body[:n])
+		if err != nil {
+			return nil, fmt.Errorf("query binary: column %q: %w", r.Columns[i], err)
+		}
+		row[i] = v
+		body = body[n:]
+	}
+	return row, nil
+}
+
+// postgresEpoch is the zero point of binary dates and timestamps.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// decodeBinary decodes a value in the binary format of the type typeID.
+func decodeBinary(typeID uint32, b []byte) (any, error) {
+	size := func(n int) error {
+		if len(b) != n {
+			return fmt.Errorf("type %d: want %d bytes, got %d", typeID, n, len(b))
+		}
+		return nil
+	}
+
+	switch typeID {
+	case boolOID:
+		if err := size(1); err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case int2OID:
+		if err := size(2); err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case int4OID:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case int8OID:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case oidOID:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint32(b), nil
+	case float4OID:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case float8OID:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case textOID, varcharOID, nameOID, bpcharOID, jsonOID:
+		return string(b), nil
+	case jsonbOID:
+		if len(b) == 0 || b[0] != 1 {
+			return nil, errors.New("unsupported jsonb format version")
+		}
+		return string(b[1:]), nil
+	case byteaOID:
+		return bytes.Clone(b), nil
+	case uuidOID:
+		if err := size(16); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+	case dateOID:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		days := int32(binary.BigEndian.Uint32(b))
+		if days == math.MaxInt32 || days == math.MinInt32 {
+			return nil, errors.New("infinite date")
+		}
+		return postgresEpoch.AddDate(0, 0, int(days)), nil
+	case timestampOID, timestamptzOID:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		us := int64(binary.BigEndian.Uint64(b))
+		if us == math.MaxInt64 || us == math.MinInt64 {
+			return nil, errors.New("infinite timestamp")
+		}
+		return postgresEpoch.Add(time.Duration(us/1e6) * time.Second).Add(time.Duration(us%1e6) * time.Microsecond), nil
+	case numericOID:
+		return decodeNumeric(b)
+	}
+	return bytes.Clone(b), nil
+}
+
+// decodeNumeric decodes a numeric in binary format: the number of base
+// 10000 digits, the weight of the first, the sign, the display scale and
+// the digits, all 16-bit.
+func decodeNumeric(b []byte) (*big.Rat, error) {
+	if len(b) < 8 {
+		return nil, errors.New("malformed numeric")
+	}
+	ndigits := int(binary.BigEndian.Uint16(b))
+	weight := int(int16(binary.BigEndian.Uint16(b[2:])))
+	sign := binary.BigEndian.Uint16(b[4:])
+	if len(b) != 8+2*ndigits {
+		return nil, errors.New("malformed numeric")
+	}
+	switch sign {
+	case 0x0000, 0x4000:
+	case 0xC000:
+		return nil, errors.New("numeric NaN")
+	case 0xD000, 0xF000:
+		return nil, errors.New("infinite numeric")
+	default:
+		return nil, fmt.Errorf("malformed numeric sign %#x", sign)
+	}
+
+	n := new(big.Int)
+	base := big.NewInt(10000)
+	for i := 0; i < ndigits; i++ {
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(binary.BigEndian.Uint16(b[8+2*i:]))))
+	}
+	// The digits so far are worth 10000^(weight+1-ndigits) each.
+	r := new(big.Rat).SetInt(n)
+	if exp := weight + 1 - ndigits; exp > 0 {
+		r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(base, big.NewInt(int64(exp)), nil)))
+	} else if exp < 0 {
+		r.Quo(r, new(big.Rat).SetInt(new(big.Int).Exp(base, big.NewInt(int64(-exp)), nil)))
+	}
+	if sign == 0x4000 {
+		r.Neg(r)
+	}
+	return r, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeNumeric(t *testing.T) {
+	// numeric builds the binary form of a numeric from its base 10000
+	// digits.
+	numeric := func(weight int16, sign uint16, digits ...uint16) []byte {
+		b := binary.BigEndian.AppendUint16(nil, uint16(len(digits)))
+		b = binary.BigEndian.AppendUint16(b, uint16(weight))
+		b = binary.BigEndian.AppendUint16(b, sign)
+		b = binary.BigEndian.AppendUint16(b, 0)
+		for _, d := range digits {
+			b = binary.BigEndian.AppendUint16(b, d)
+		}
+		return b
+	}
+	tests := []struct {
+		in   []byte
+		want string
+	}{
+		{numeric(0, 0), "0"},
+		{numeric(0, 0, 42), "42"},
+		{numeric(1, 0, 1, 2345), "12345"},
+		{numeric(2, 0, 7), "700000000"},
+		{numeric(0, 0x4000, 3, 1415, 9265), "-3.14159265"},
+		{numeric(-1, 0, 5), "0.0005"},
+		{numeric(-2, 0, 1), "0.00000001"},
+	}
+	for _, tt := range tests {
+		got, err := decodeNumeric(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.want, err)
+			continue
+		}
+		want, _ := new(big.Rat).SetString(tt.want)
+		if got.Cmp(want) != 0 {
+			t.Errorf("decoded %s, want %s", got.RatString(), tt.want)
+		}
+	}
+
+	for _, bad := range [][]byte{numeric(0, 0xC000), numeric(0, 0xD000), nil, numeric(0, 0, 1)[:9]} {
+		if _, err := decodeNumeric(bad); err == nil {
+			t.Errorf("decodeNumeric(%x): expected an error", bad)
+		}
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	us := int64(-1500000) // 1.5s before the epoch
+	ts := binary.BigEndian.AppendUint64(nil, uint64(us))
+	got, err := decodeBinary(timestamptzOID, ts)
+	if want := time.Date(1999, 12, 31, 23, 59, 58, 500000000, time.UTC); err != nil || !got.(time.Time).Equal(want) {
+		t.Errorf("timestamptz: got %v, %v, want %v", got, err, want)
+	}
+	got, err = decodeBinary(dateOID, binary.BigEndian.AppendUint32(nil, 366))
+	if want := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC); err != nil || !got.(time.Time).Equal(want) {
+		t.Errorf("date: got %v, %v, want %v", got, err, want)
+	}
+	got, err = decodeBinary(uuidOID, []byte{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11})
+	if err != nil || got != "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11" {
+		t.Errorf("uuid: got %v, %v", got, err)
+	}
+	if _, err := decodeBinary(int4OID, []byte{1, 2}); err == nil {
+		t.Error("int4: expected an error for a short value")
+	}
+	if got, _ := decodeBinary(1186, []byte{1, 2, 3}); !bytes.Equal(got.([]byte), []byte{1, 2, 3}) {
+		t.Errorf("unknown type: got %v, want raw bytes", got)
+	}
+}
+
+func TestQueryBinary(t *testing.T) {
+	res, err := testPG.QueryBinary(`SELECT 123456789012345678901234567890.000000000000000001::numeric AS n,
+		'\x00ff5c78'::bytea AS b,
+		TIMESTAMPTZ '2024-10-17 12:34:56.789012+02' AS ts,
+		true AS ok, 42::int2 AS small, 'txt'::text AS s, NULL::int4 AS missing;`)
+	if err != nil {
+		t.Fatalf("QueryBinary: %v", err)
+	}
+	if want := "n,b,ts,ok,small,s,missing"; strings.Join(res.Columns, ",") != want {
+		t.Errorf("columns = %v, want %s", res.Columns, want)
+	}
+	if len(res.Rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(res.Rows))
+	}
+	row := res.Rows[0]
+
+	want, _ := new(big.Rat).SetString("123456789012345678901234567890.000000000000000001")
+	if n, ok := row[0].(*big.Rat); !ok || n.Cmp(want) != 0 {
+		t.Errorf("numeric: got %v", row[0])
+	}
+	if b, ok := row[1].([]byte); !ok || !bytes.Equal(b, []byte{0x00, 0xff, 0x5c, 0x78}) {
+		t.Errorf("bytea: got %v", row[1])
+	}
+	wantTS := time.Date(2024, 10, 17, 10, 34, 56, 789012000, time.UTC)
+	if ts, ok := row[2].(time.Time); !ok || !ts.Equal(wantTS) {
+		t.Errorf("timestamptz: got %v, want %v", row[2], wantTS)
+	}
+	if row[3] != true || row[4] != int64(42) || row[5] != "txt" || row[6] != nil {
+		t.Errorf("unexpected values %v", row[3:])
+	}
+
+	_, err = testPG.QueryBinary("SELECT * FROM no_such_table;")
+	if qerr, ok := err.(*QueryError); !ok || qerr.Code != "42P01" {
+		t.Errorf("expected an undefined_table QueryError, got %v", err)
+	}
+	if _, err := testPG.QueryBinary("SELECT 'NaN'::numeric;"); err == nil {
+		t.Error("expected an error for numeric NaN")
+	}
+}
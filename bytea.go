@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecodeBytea decodes a bytea value from the text PostgreSQL prints for
+// it: \x and two hex digits a byte, as by default, or the escape format
+// that bytea_output = 'escape' selects, where bytes other than printable
+// ASCII are written \nnn in octal and a backslash as \\. Scan, Select and
+// QueryMaps decode bytea with it; encoding needs no helper, as a []byte
+// argument to QueryParams or InsertRows becomes a bytea literal.
+func DecodeBytea(s string) ([]byte, error) {
+	if digits, ok := strings.CutPrefix(s, `\x`); ok {
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea: %w", err)
+		}
+		return b, nil
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if strings.HasPrefix(s[i:], `\\`) {
+			b = append(b, '\\')
+			i++
+			continue
+		}
+		if i+4 > len(s) {
+			return nil, errors.New("invalid bytea: truncated escape")
+		}
+		n, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea escape %q", s[i:i+4])
+		}
+		b = append(b, byte(n))
+		i += 3
+	}
+	return b, nil
+}
+
+// InsertBytea inserts a row into table with id in its id column and data
+// in the bytea column col, and returns any error. table is used as
+// written, so it may be schema-qualified; col is quoted, and id is quoted
+// as a literal, as QueryParams does.
+//
+// data may be of any size: rather than going in the INSERT, which has to
+// fit in a single message to the backend, it is streamed into a temporary
+// table with COPY FROM STDIN and inserted from there. The steps run
+// without letting other statements in between, so concurrent calls do not
+// share the temporary table.
+func (p *PGLite) InsertBytea(table, col string, id any, data []byte) error {
+	idLit, err := literal(id)
+	if err != nil {
+		return fmt.Errorf("insert bytea: id: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.query(p.ctx, "DROP TABLE IF EXISTS pg_temp.pglite_bytea; CREATE TEMP TABLE pglite_bytea (b bytea);", nil, &commandTag{}); err != nil {
+		return fmt.Errorf("insert bytea: %w", err)
+	}
+	defer p.query(p.ctx, "DROP TABLE IF EXISTS pg_temp.pglite_bytea;", nil, &commandTag{})
+
+	// In COPY's text format, the backslash of \x is doubled.
+	row := `\\x` + hex.EncodeToString(data) + "\n"
+	if _, err := p.copyRows("COPY pg_temp.pglite_bytea FROM STDIN;", strings.NewReader(row)); err != nil {
+		return fmt.Errorf("insert bytea: %w", err)
+	}
+	err = p.query(p.ctx, "INSERT INTO "+table+" (id, "+quoteIdent(col)+") SELECT "+idLit+", b FROM pg_temp.pglite_bytea;", nil, &commandTag{})
+	if err != nil {
+		return fmt.Errorf("insert bytea: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDecodeBytea(t *testing.T) {
+	for s, want := range map[string][]byte{
+		`\x`:                {},
+		`\x00ff5c78`:        {0, 0xff, '\\', 'x'},
+		`ab\000\377\\c`:     {'a', 'b', 0, 0xff, '\\', 'c'},
+		"plain text, as is": []byte("plain text, as is"),
+	} {
+		got, err := DecodeBytea(s)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("DecodeBytea(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{`\x0`, `\xzz`, `a\4`, `\400`, `\9`} {
+		if _, err := DecodeBytea(s); err == nil {
+			t.Errorf("DecodeBytea(%q): expected an error", s)
+		}
+	}
+}
+
+func TestInsertBytea(t *testing.T) {
+	if err := testPG.Query("CREATE TABLE bytea_test (id text PRIMARY KEY, data bytea);"); err != nil {
+		t.Fatal(err)
+	}
+	small := []byte{0, 1, 0x7f, 0x80, 0xff, '\\', '\'', 'x', 0}
+	// Larger than a message to the backend can carry.
+	large := bytes.Repeat([]byte{0, 0xfe, 'a', '\\'}, 64<<10)
+	for id, data := range map[string][]byte{"small": small, "large": large, "empty": {}} {
+		if err := testPG.InsertBytea("bytea_test", "data", id, data); err != nil {
+			t.Fatalf("InsertBytea %s: %v", id, err)
+		}
+		var got []byte
+		if err := testPG.QueryRow("SELECT data FROM bytea_test WHERE id = '" + id + "';").Scan(&got); err != nil {
+			t.Fatalf("Scan %s: %v", id, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %d bytes back, want %d equal to those inserted", id, len(got), len(data))
+		}
+	}
+	if err := testPG.InsertBytea("bytea_test", "data", "small", nil); err == nil || !strings.Contains(err.Error(), "duplicate key") {
+		t.Errorf("duplicate id: got %v", err)
+	}
+
+	// With the escape output format too.
+	if err := testPG.Query("SET bytea_output = 'escape';"); err != nil {
+		t.Fatal(err)
+	}
+	maps, err := testPG.QueryMaps("SELECT data FROM bytea_test WHERE id = 'small';")
+	testPG.Query("RESET bytea_output;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := maps[0]["data"].([]byte); !bytes.Equal(got, small) {
+		t.Errorf("QueryMaps: got %v, want %v", maps[0]["data"], small)
+	}
+
+	var selected []struct {
+		ID   string
+		Data []byte
+	}
+	if err := testPG.Select(&selected, "SELECT id, data FROM bytea_test WHERE id = 'small';"); err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 1 || !bytes.Equal(selected[0].Data, small) {
+		t.Errorf("Select: got %+v", selected)
+	}
+
+	res, err := testPG.QueryRows("SELECT data FROM bytea_test WHERE id = 'small';")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	if err := (&rows{res: res}).Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := dest[0].([]byte); !bytes.Equal(got, small) {
+		t.Errorf("driver rows: got %v, want %v", dest[0], small)
+	}
+}
+
+func TestInsertByteaConcurrent(t *testing.T) {
+	if err := testPG.Query("CREATE TABLE bytea_concurrent (id integer PRIMARY KEY, data bytea);"); err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	payload := func(i int) []byte { return bytes.Repeat([]byte{byte(i)}, 10000+i) }
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- testPG.InsertBytea("bytea_concurrent", "data", i, payload(i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("InsertBytea: %v", err)
+		}
+	}
+	for i := range n {
+		var got []byte
+		if err := testPG.QueryRow(fmt.Sprintf("SELECT data FROM bytea_concurrent WHERE id = %d;", i)).Scan(&got); err != nil {
+			t.Fatalf("Scan %d: %v", i, err)
+		}
+		if !bytes.Equal(got, payload(i)) {
+			t.Errorf("id %d: got %d bytes, not its own payload", i, len(got))
+		}
+	}
+}
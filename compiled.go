@@ -0,0 +1,95 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// CompiledPGLite is the embedded PGLite module compiled once, so that many
+// instances can be started without paying the compilation cost each time.
+// Its instances share a wazero runtime but each has its own memory and
+// data directory.
+type CompiledPGLite struct {
+	m *compiledModule
+}
+
+// CompileShared compiles the embedded module. An optional
+// wazero.RuntimeConfig configures the shared runtime, as for NewPGLite;
+// setting a wazero.CompilationCache on it also lets other runtimes,
+// including ones in other processes when the cache is backed by a
+// directory, reuse the compilation. Without a config, a fallback to the
+// interpreter is warned of on os.Stderr. It needs the embedded tarball, so
+// fails in programs built with the pglite_noembed tag.
+func CompileShared(ctx context.Context, rtConfig ...wazero.RuntimeConfig) (*CompiledPGLite, error) {
+	blob, err := embeddedWASM()
+	if err != nil {
+		return nil, err
+	}
+	var cfg wazero.RuntimeConfig
+	if len(rtConfig) > 0 {
+		cfg = rtConfig[0]
+	}
+	m, err := compile(ctx, blob, cfg, 0, quietLogger{NewLogger(os.Stderr)})
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledPGLite{m: m}, nil
+}
+
+// NewFromCompiled starts an instance of c, extracting the embedded cluster
+// into opts.DataDir as NewPGLiteWithOptions does. Instances sharing c must
+// use different data directories. opts.RuntimeConfig and
+// opts.MemoryLimitPages are ignored; set a limit on the RuntimeConfig
+// passed to CompileShared instead. Closing the instance leaves c usable.
+func NewFromCompiled(ctx context.Context, c *CompiledPGLite, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	return readOnlyCopy(ctx, opts, func(opts Options) (*PGLite, error) {
+		return tempDataDir(opts, func(opts Options) (*PGLite, error) {
+			if err := opts.reset(); err != nil {
+				return nil, err
+			}
+			if err := extractEnv(ctx, opts.dataDir(), opts.TarballFS, opts.logger(stderr)); err != nil {
+				return nil, err
+			}
+			return c.m.instantiate(ctx, stdout, stderr, opts)
+		})
+	})
+}
+
+// Close releases the runtime, closing any instances still running in it.
+func (c *CompiledPGLite) Close(ctx context.Context) error {
+	return c.m.runtime.Close(ctx)
+}
+
+// embeddedWASM reads the postgres module out of the embedded tarball.
+func embeddedWASM() ([]byte, error) {
+	tarball, err := openTarball(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tarball.Close()
+	gr, err := gzip.NewReader(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("embedded tarball has no postgres module")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == "tmp/pglite/bin/postgres.wasi" {
+			return io.ReadAll(tr)
+		}
+	}
+}
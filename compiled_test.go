@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestCompileShared(t *testing.T) {
+	ctx := context.Background()
+	c, err := CompileShared(ctx)
+	if err != nil {
+		t.Fatalf("CompileShared: %v", err)
+	}
+	defer c.Close(ctx)
+
+	for i := 0; i < 2; i++ {
+		pg, err := NewFromCompiled(ctx, c, io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewFromCompiled %d: %v", i, err)
+		}
+		var n int
+		if err := pg.QueryRow("SELECT 2 * 21;").Scan(&n); err != nil || n != 42 {
+			t.Errorf("instance %d: got %d, %v", i, n, err)
+		}
+		pg.Close()
+	}
+}
+
+func BenchmarkNewFromCompiled(b *testing.B) {
+	ctx := context.Background()
+	c, err := CompileShared(ctx)
+	if err != nil {
+		b.Fatalf("CompileShared: %v", err)
+	}
+	defer c.Close(ctx)
+	dir := b.TempDir()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pg, err := NewFromCompiled(ctx, c, io.Discard, io.Discard, Options{DataDir: dir})
+		if err != nil {
+			b.Fatalf("NewFromCompiled: %v", err)
+		}
+		pg.Close()
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CopyFrom loads CSV data read from r into the named columns of table, or
+// into all of its columns if columns is empty, and returns the number of
+// rows loaded, as in the "COPY N" command tag. table is used as written, so
+// it may be schema-qualified; column names are quoted. r is streamed to the
+// backend as CopyFromStdin does.
+func (p *PGLite) CopyFrom(table string, columns []string, r io.Reader) (int64, error) {
+	target := table
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, c := range columns {
+			quoted[i] = quoteIdent(c)
+		}
+		target += " (" + strings.Join(quoted, ", ") + ")"
+	}
+	return p.CopyFromStdin("COPY "+target+" FROM STDIN WITH (FORMAT csv);", r)
+}
+
+// CopyFormat is a data format understood by COPY.
+type CopyFormat string
+
+const (
+	CopyCSV    CopyFormat = "csv"
+	CopyText   CopyFormat = "text"
+	CopyBinary CopyFormat = "binary"
+)
+
+// CopyTo runs COPY (query) TO in the given format and writes the data to
+// w, unchanged, so quotes and embedded newlines are escaped as the format
+// requires. The data passes through a file under the data directory's tmp
+// subdirectory rather than the module's stdout.
+func (p *PGLite) CopyTo(w io.Writer, query string, format CopyFormat) error {
+	switch format {
+	case CopyCSV, CopyText, CopyBinary:
+	default:
+		return fmt.Errorf("copy to: unknown format %q", format)
+	}
+
+	f, err := os.CreateTemp(filepath.Join(p.dataDir, "tmp"), "pglite-copy-*")
+	if err != nil {
+		return fmt.Errorf("copy to: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	path, err := quoteString(guestTmpPath(f.Name()))
+	if err != nil {
+		return fmt.Errorf("copy to: %w", err)
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	if err := p.Query("COPY (" + query + ") TO " + path + " WITH (FORMAT " + string(format) + ");"); err != nil {
+		return err
+	}
+
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return fmt.Errorf("copy to: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("copy to: %w", err)
+	}
+	return nil
+}
+
+// copyRows runs a COPY statement and returns the row count from its
+// command tag, "COPY N". If data is not nil, it is fed to the statement,
+// a COPY FROM STDIN, when it runs. The caller must hold p.mu.
+func (p *PGLite) copyRows(stmt string, data io.Reader) (int64, error) {
+	tag := &copyFeed{data: data}
+	if err := p.query(p.ctx, stmt, nil, tag); err != nil {
+		return 0, err
+	}
+	n, ok := strings.CutPrefix(tag.tag, "COPY ")
+	if !ok {
+		return 0, errors.New("copy: no row count")
+	}
+	return strconv.ParseInt(n, 10, 64)
+}
+
+// copyChunk is the most data sent in one CopyData message, which the
+// backend must read from the socket file in one go.
+const copyChunk = maxMessageSize - 5
+
+// CopyFromStdin runs stmt, a COPY ... FROM STDIN statement, feeding it the
+// data read from r, and returns the number of rows loaded. The data is in
+// whatever format stmt names, and is handed to the backend in chunks as it
+// reads them rather than staged in a file first, so r may be a stream of
+// any length. A failure to read r fails the COPY with a *QueryError
+// carrying the read error's text; a context done while it runs closes the
+// instance, as for QueryContext.
+func (p *PGLite) CopyFromStdin(stmt string, r io.Reader) (int64, error) {
+	if !copiesFromStdin(sqlWords(stmt)) {
+		return 0, errors.New("copy from stdin: not a COPY ... FROM STDIN statement")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.copyRows(stmt, r)
+}
+
+// copiesFromStdin reports whether a statement's words are those of a COPY
+// FROM STDIN.
+func copiesFromStdin(words []string) bool {
+	if len(words) == 0 || words[0] != "COPY" {
+		return false
+	}
+	for i := 1; i+1 < len(words); i++ {
+		if words[i] == "FROM" && words[i+1] == "STDIN" {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFeed is the rowHandler of a COPY, which keeps its command tag and
+// carries the data of a COPY FROM STDIN to the call that runs it, rather
+// than to whatever statement reaches the backend first, such as those a
+// restart runs to restore the session.
+type copyFeed struct {
+	commandTag
+	data io.Reader
+}
+
+// feedCopy streams r to the backend as the data of a COPY FROM STDIN, in
+// the background, and returns a function that stops it. The caller must
+// hold p.mu, call into the backend, and stop the feed once the call
+// returns.
+//
+// While a COPY FROM STDIN runs, the backend does not return from
+// interactive_one, nor read the module's stdin, but waits for CopyData
+// messages in the socket file it writes its reply to, removing each file it
+// reads. So the data has to be written during the call, by another
+// goroutine: one message per file, each once the file before is gone,
+// ending with CopyDone at the end of r, or CopyFail if reading r fails. A
+// file is linked into place rather than renamed, so that it never replaces
+// a reply the backend wrote after failing; one left unread is replaced by
+// the reply in turn.
+func (p *PGLite) feedCopy(r io.Reader) (stop func()) {
+	out := filepath.Join(p.dataDir, sockOut)
+	lock := filepath.Join(p.dataDir, sockLockCopy)
+	done := make(chan struct{})
+	var (
+		mu      sync.Mutex // held while writing a file, and by stop
+		stopped bool
+	)
+
+	// put writes msg once the backend has read the file before, and
+	// reports whether the feed should go on.
+	put := func(msg []byte) bool {
+		for {
+			if _, err := os.Lstat(out); errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			select {
+			case <-done:
+				return false
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return false
+		}
+		if err := os.WriteFile(lock, msg, 0o600); err != nil {
+			p.log.Errorf("copy from stdin: %v", err)
+			return false
+		}
+		defer os.Remove(lock)
+		// An error linking means a reply is in the way: the COPY has
+		// failed.
+		return os.Link(lock, out) == nil
+	}
+
+	go func() {
+		buf := make([]byte, copyChunk)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 && !put(appendMessage(nil, 'd', buf[:n])) {
+				return
+			}
+			switch err {
+			case nil:
+			case io.EOF, io.ErrUnexpectedEOF:
+				put(appendMessage(nil, 'c', nil))
+				return
+			default:
+				put(appendMessage(nil, 'f', append([]byte(err.Error()), 0)))
+				return
+			}
+		}
+	}()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		close(done)
+	}
+}
+
+// guestTmpPath returns the path the sandbox sees for a file directly in
+// the data directory's tmp subdirectory, which is mounted at /tmp.
+func guestTmpPath(hostPath string) string {
+	return "/tmp/" + filepath.Base(hostPath)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCopyFrom(t *testing.T) {
+	dir := t.TempDir()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.Query("CREATE TABLE copy_in (id integer, note text, extra text DEFAULT 'x');"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	csv := "1,plain\n2,\"with, comma\"\n3,\"two\nlines\"\n4,\n"
+	n, err := pg.CopyFrom("copy_in", []string{"id", "note"}, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("CopyFrom: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("expected 4 rows copied, got %d", n)
+	}
+
+	res, err := pg.QueryRows("SELECT note, extra FROM copy_in ORDER BY id;")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	want := []string{"plain", "with, comma", "two\nlines", ""}
+	for i, w := range want {
+		if res.Rows[i][0] != w || res.Rows[i][1] != "x" {
+			t.Errorf("row %d: got %q, want %q", i, res.Rows[i], w)
+		}
+	}
+	if !res.IsNull(3, 0) {
+		t.Error("expected an empty unquoted field to load as NULL")
+	}
+
+	// A second copy works too, and failures leave no staging files behind.
+	if n, err := pg.CopyFrom("copy_in", nil, strings.NewReader("5,five,y\n")); err != nil || n != 1 {
+		t.Errorf("second CopyFrom: %d, %v", n, err)
+	}
+	if _, err := pg.CopyFrom("copy_in", nil, strings.NewReader("not a number\n")); err == nil {
+		t.Error("expected an error for bad input")
+	}
+	staged, _ := filepath.Glob(filepath.Join(dir, "tmp", "pglite-copy-*"))
+	if len(staged) > 0 {
+		t.Errorf("staging files left behind: %v", staged)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	query := `SELECT * FROM (VALUES (1, 'say "hi"'), (2, E'two\nlines'), (3, NULL)) v (id, note) ORDER BY id;`
+	tests := []struct {
+		format CopyFormat
+		want   string
+	}{
+		{CopyCSV, "1,\"say \"\"hi\"\"\"\n2,\"two\nlines\"\n3,\n"},
+		{CopyText, "1\tsay \"hi\"\n2\ttwo\\nlines\n3\t\\N\n"},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		if err := pg.CopyTo(&buf, query, tt.format); err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	var bin strings.Builder
+	if err := pg.CopyTo(&bin, query, CopyBinary); err != nil {
+		t.Fatalf("binary: %v", err)
+	}
+	if !strings.HasPrefix(bin.String(), "PGCOPY\n\xff\r\n\x00") {
+		t.Errorf("binary output lacks the PGCOPY signature: %q", bin.String())
+	}
+	if err := pg.CopyTo(io.Discard, query, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestCopyFromStdin(t *testing.T) {
+	if err := testPG.Query("DROP TABLE IF EXISTS copy_stdin; CREATE TABLE copy_stdin (id integer, note text);"); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int64 {
+		t.Helper()
+		n, err := testPG.QueryCount("SELECT * FROM copy_stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// Written as it is read, and longer than one chunk.
+	pr, pw := io.Pipe()
+	go func() {
+		for i := range 20000 {
+			fmt.Fprintf(pw, "%d\tnote %d\n", i, i)
+		}
+		pw.Close()
+	}()
+	n, err := testPG.CopyFromStdin("COPY copy_stdin FROM STDIN;", pr)
+	if err != nil || n != 20000 {
+		t.Fatalf("CopyFromStdin: %d, %v", n, err)
+	}
+	n, err = testPG.CopyFromStdin("copy copy_stdin (note, id) from stdin with (format csv)", strings.NewReader("\"a, b\",-1\n"))
+	if err != nil || n != 1 {
+		t.Fatalf("CopyFromStdin csv: %d, %v", n, err)
+	}
+	if got := count(); got != 20001 {
+		t.Errorf("got %d rows, want 20001", got)
+	}
+
+	// Bad data partway through fails the whole COPY.
+	var bad strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&bad, "%d\tx\n", i)
+	}
+	bad.WriteString("oops\tx\n")
+	bad.WriteString(strings.Repeat("1\tx\n", 5000))
+	_, err = testPG.CopyFromStdin("COPY copy_stdin FROM STDIN;", strings.NewReader(bad.String()))
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "22P02" {
+		t.Errorf("bad data: got %v", err)
+	}
+	_, err = testPG.CopyFromStdin("COPY copy_stdin FROM STDIN;", io.MultiReader(strings.NewReader("1\tx\n"), iotest.ErrReader(errors.New("disk on fire"))))
+	if !errors.As(err, &qerr) || !strings.Contains(qerr.Message, "disk on fire") {
+		t.Errorf("read error: got %v", err)
+	}
+	if got := count(); got != 20001 {
+		t.Errorf("after failures got %d rows, want 20001", got)
+	}
+
+	if _, err := testPG.CopyFromStdin("COPY copy_stdin TO STDOUT;", strings.NewReader("")); err == nil {
+		t.Error("expected COPY TO to be refused")
+	}
+}
+
+func TestCopyFromStdinAfterSuspend(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE copy_resume (id integer);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.SetParam("work_mem", "8MB"); err != nil {
+		t.Fatal(err)
+	}
+	pg.mu.Lock()
+	err = pg.suspend()
+	pg.mu.Unlock()
+	if err != nil {
+		t.Fatalf("suspend: %v", err)
+	}
+
+	// Resuming runs statements to restore the session before the COPY,
+	// which must not take its data.
+	n, err := pg.CopyFromStdin("COPY copy_resume FROM STDIN;", strings.NewReader("1\n2\n3\n"))
+	if err != nil || n != 3 {
+		t.Fatalf("CopyFromStdin: %d, %v", n, err)
+	}
+}
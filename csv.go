@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions configures ImportCSV.
+type CSVOptions struct {
+	// Delimiter separates fields; if zero, it is a comma. Fields may be
+	// quoted with double quotes, which are doubled inside a field.
+	Delimiter rune
+
+	// CreateTable creates the table before loading it, with a column for
+	// each field of the header, typed from the first SampleRows rows: as
+	// bigint if every value there is an integer, numeric if every value is
+	// a number, and text otherwise. Empty values do not count against a
+	// type; a column with none but empty values is text.
+	CreateTable bool
+
+	// SampleRows is how many rows CreateTable looks at; if zero, 100.
+	SampleRows int
+}
+
+// ImportCSV loads CSV data read from r into table and returns the number
+// of rows loaded. The first row is a header naming the table's columns the
+// fields go to, in order; other columns get their defaults. table is used
+// as written, so it may be schema-qualified. Empty values, quoted or not,
+// are loaded as NULL. The rows are loaded with CopyFrom, in one COPY, so
+// either all are loaded or none.
+func (p *PGLite) ImportCSV(table string, r io.Reader, opts CSVOptions) (int64, error) {
+	cr := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		cr.Comma = opts.Delimiter
+	}
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, errors.New("import csv: no header")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("import csv: %w", err)
+	}
+
+	var sample [][]string
+	if opts.CreateTable {
+		n := opts.SampleRows
+		if n <= 0 {
+			n = 100
+		}
+		for len(sample) < n {
+			row, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, fmt.Errorf("import csv: %w", err)
+			}
+			sample = append(sample, row)
+		}
+		cols := make([]string, len(header))
+		for i, name := range header {
+			cols[i] = quoteIdent(name) + " " + inferCSVType(sample, i)
+		}
+		if err := p.Query("CREATE TABLE " + table + " (" + strings.Join(cols, ", ") + ");"); err != nil {
+			return 0, fmt.Errorf("import csv: %w", err)
+		}
+	}
+
+	// Write the rows back out as the comma-separated CSV that CopyFrom
+	// takes, streaming them as they are read.
+	pr, pw := io.Pipe()
+	go func() {
+		cw := csv.NewWriter(pw)
+		err := cw.WriteAll(sample)
+		for err == nil {
+			var row []string
+			if row, err = cr.Read(); err == nil {
+				err = cw.Write(row)
+			}
+		}
+		if err == io.EOF {
+			cw.Flush()
+			err = cw.Error()
+		}
+		pw.CloseWithError(err)
+	}()
+	n, err := p.CopyFrom(table, header, pr)
+	pr.Close()
+	if err != nil {
+		return 0, fmt.Errorf("import csv: %w", err)
+	}
+	return n, nil
+}
+
+// inferCSVType returns the type for column col of rows, as
+// CSVOptions.CreateTable describes.
+func inferCSVType(rows [][]string, col int) string {
+	typ := "text" // if there are no values
+	for _, row := range rows {
+		v := strings.TrimSpace(row[col])
+		if v == "" {
+			continue
+		}
+		if typ == "text" {
+			typ = "bigint"
+		}
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			continue
+		}
+		// ParseFloat also takes hexadecimal, underscores, Inf and NaN.
+		digits := strings.Trim(v, "0123456789+-.eE") == ""
+		if _, err := strconv.ParseFloat(v, 64); err == nil && digits {
+			typ = "numeric"
+			continue
+		}
+		return "text"
+	}
+	return typ
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	data := "id;price;Name;note\n" +
+		"1;2.50;\"semi;colon\";\n" +
+		"2;3;\"two\nlines\";x\n" +
+		"3;;\"say \"\"hi\"\"\";y\n"
+	n, err := pg.ImportCSV("items", strings.NewReader(data), CSVOptions{Delimiter: ';', CreateTable: true})
+	if err != nil || n != 3 {
+		t.Fatalf("ImportCSV: %d, %v", n, err)
+	}
+
+	cols, err := pg.Columns("items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, c := range cols {
+		types = append(types, c.Name+" "+c.Type)
+	}
+	if got := strings.Join(types, ", "); got != "id bigint, price numeric, Name text, note text" {
+		t.Errorf("columns: %s", got)
+	}
+
+	res, err := pg.QueryRows(`SELECT id, price, "Name", note FROM items ORDER BY id;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"1", "2.50", "semi;colon", ""},
+		{"2", "3", "two\nlines", "x"},
+		{"3", "", `say "hi"`, "y"},
+	}
+	for i, row := range want {
+		if strings.Join(res.Rows[i], "|") != strings.Join(row, "|") {
+			t.Errorf("row %d: got %q, want %q", i, res.Rows[i], row)
+		}
+	}
+	if !res.IsNull(0, 3) || !res.IsNull(2, 1) {
+		t.Error("empty values not loaded as NULL")
+	}
+
+	// Into an existing table, by the header's column names.
+	n, err = pg.ImportCSV("items", strings.NewReader("Name,id\nfour,4\n"), CSVOptions{})
+	if err != nil || n != 1 {
+		t.Errorf("ImportCSV into existing table: %d, %v", n, err)
+	}
+
+	if _, err := pg.ImportCSV("items", strings.NewReader("id\n5,6\n"), CSVOptions{}); err == nil {
+		t.Error("expected an error for a row with too many fields")
+	}
+	if _, err := pg.ImportCSV("items", strings.NewReader(""), CSVOptions{}); err == nil {
+		t.Error("expected an error without a header")
+	}
+	var count int
+	if err := pg.QueryRow("SELECT count(*) FROM items;").Scan(&count); err != nil || count != 4 {
+		t.Errorf("count: got %d, %v", count, err)
+	}
+}
+
+func TestInferCSVType(t *testing.T) {
+	rows := [][]string{
+		{"1", "1.5", "a", "", "1e3", "Inf"},
+		{"-2", "2", "3", "", "2", "1"},
+	}
+	for col, want := range []string{"bigint", "numeric", "text", "text", "numeric", "text"} {
+		if got := inferCSVType(rows, col); got != want {
+			t.Errorf("column %d: got %s, want %s", col, got, want)
+		}
+	}
+}
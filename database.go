@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+)
+
+// CreateDatabase creates a database in the cluster. Use UseDatabase to
+// connect to it.
+func (p *PGLite) CreateDatabase(name string) error {
+	return p.Query("CREATE DATABASE " + quoteIdent(name) + ";")
+}
+
+// Database returns the name of the database the session is connected to.
+func (p *PGLite) Database() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.opts.database()
+}
+
+// UseDatabase connects the instance to another database in the cluster.
+//
+// The single-user backend is bound to the database it started on, so this
+// runs a CHECKPOINT, stops the module and starts a new one on the named
+// database, in the same data directory. Session state such as settings
+// and temporary tables is lost, apart from settings made with SetParam,
+// channels listened on with Listen and statements prepared with Prepare;
+// an open transaction is rolled back. If the new start fails, the instance is
+// restarted on the previous database and the error returned.
+func (p *PGLite) UseDatabase(name string) error {
+	res, err := p.QueryRowsParams("SELECT 1 FROM pg_database WHERE datname = $1;", name)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) == 0 {
+		return fmt.Errorf("use database: database %q does not exist", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	if err := p.query(p.ctx, "CHECKPOINT;", nil, nil); err != nil {
+		return fmt.Errorf("use database: %w", err)
+	}
+
+	opts := p.opts
+	opts.Database = name
+	err = p.restart(opts)
+	if err == nil {
+		return nil
+	}
+	if rerr := p.restart(p.opts); rerr != nil {
+		p.close(p.ctx)
+		return fmt.Errorf("use database: %w (restarting on %s: %v)", err, p.opts.database(), rerr)
+	}
+	return fmt.Errorf("use database: %w", err)
+}
+
+// restart replaces the instance's module with a new one started with opts,
+// which ends any open transaction. The caller must hold p.mu.
+func (p *PGLite) restart(opts Options) error {
+	// The statements that restore the session run whatever transaction
+	// the restart ends, even in a restart after one of them fails.
+	p.restarts++
+	restarting := p.restarting
+	p.restarting = true
+	defer func() { p.restarting = restarting }()
+	p.mod.Close(p.ctx)
+	np, err := p.compiled.start(p.ctx, p.stdout, p.stderr, opts)
+	if err != nil {
+		return err
+	}
+	// The new module's output follows the old one's for LastOutput.
+	p.errOut.tail.Write(np.errOut.tail.b)
+	np.errOut.tail = p.errOut.tail
+	p.mod, p.outOut, p.errOut = np.mod, np.outOut, np.errOut
+	p.opts = opts
+	p.suspended = false
+	if err := p.applyParams(); err != nil {
+		return err
+	}
+	if err := p.relisten(); err != nil {
+		return err
+	}
+	p.reprepare()
+	return nil
+}
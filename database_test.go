@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestUseDatabase(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.CreateDatabase("tenant_a"); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if err := pg.UseDatabase("tenant_a"); err != nil {
+		t.Fatalf("UseDatabase: %v", err)
+	}
+	var name string
+	if err := pg.QueryRow("SELECT current_database();").Scan(&name); err != nil || name != "tenant_a" {
+		t.Fatalf("current_database: got %q, %v", name, err)
+	}
+	if pg.Database() != "tenant_a" {
+		t.Errorf("Database: got %q", pg.Database())
+	}
+	if err := pg.Query("CREATE TABLE only_in_a (n integer);"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pg.UseDatabase("missing"); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+	if err := pg.UseDatabase("postgres"); err != nil {
+		t.Fatalf("UseDatabase postgres: %v", err)
+	}
+	if err := pg.Query("SELECT * FROM only_in_a;"); err == nil {
+		t.Error("expected only_in_a to be absent from postgres")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataDir(t *testing.T) {
+	dir := t.TempDir()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "tmp/pglite/base/PG_VERSION")); err != nil {
+		t.Errorf("cluster not extracted into data dir: %v", err)
+	}
+	res, err := pg.QueryRows("SELECT current_database();")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "postgres" {
+		t.Errorf("unexpected result: %v", res.Rows)
+	}
+}
+
+func TestDataDirPersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	pg, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	if err := pg.Query("CREATE TABLE persisted (id integer);"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	pg.Close()
+
+	tableExists := func(pg *PGLite) bool {
+		t.Helper()
+		res, err := pg.QueryRows("SELECT to_regclass('persisted') IS NOT NULL;")
+		if err != nil {
+			t.Fatalf("QueryRows: %v", err)
+		}
+		return len(res.Rows) == 1 && res.Rows[0][0] == "t"
+	}
+
+	pg, err = NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if !tableExists(pg) {
+		t.Error("table from previous run is missing")
+	}
+	pg.Close()
+
+	pg, err = NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir, ResetOnStart: true})
+	if err != nil {
+		t.Fatalf("reopen with reset: %v", err)
+	}
+	defer pg.Close()
+	if tableExists(pg) {
+		t.Error("table survived ResetOnStart")
+	}
+}
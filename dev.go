@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/rand"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// devFS is the file system mounted at /dev in the sandbox. Its urandom and
+// random devices return fresh bytes from crypto/rand on every read.
+type devFS struct{}
+
+var devices = []string{"random", "urandom"}
+
+func (devFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &devDir{}, nil
+	}
+	for _, d := range devices {
+		if name == d {
+			return randomDevice{name: name}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// randomDevice is an open random device.
+type randomDevice struct {
+	name string
+}
+
+func (d randomDevice) Read(b []byte) (int, error) {
+	return rand.Read(b)
+}
+
+func (d randomDevice) Stat() (fs.FileInfo, error) {
+	return devInfo{name: d.name, mode: fs.ModeDevice | fs.ModeCharDevice | 0444}, nil
+}
+
+func (d randomDevice) Close() error {
+	return nil
+}
+
+// devDir is the open root directory of devFS.
+type devDir struct {
+	read int // entries already returned by ReadDir
+}
+
+func (d *devDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+func (d *devDir) Stat() (fs.FileInfo, error) {
+	return devInfo{name: ".", mode: fs.ModeDir | 0555}, nil
+}
+
+func (d *devDir) Close() error {
+	return nil
+}
+
+func (d *devDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for d.read < len(devices) && (n <= 0 || len(entries) < n) {
+		info, _ := randomDevice{name: devices[d.read]}.Stat()
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+		d.read++
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// devInfo describes a file in devFS.
+type devInfo struct {
+	name string
+	mode fs.FileMode
+}
+
+func (i devInfo) Name() string       { return i.name }
+func (i devInfo) Size() int64        { return 0 }
+func (i devInfo) Mode() fs.FileMode  { return i.mode }
+func (i devInfo) ModTime() time.Time { return time.Time{} }
+func (i devInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i devInfo) Sys() any           { return nil }
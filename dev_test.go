@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestDevFS(t *testing.T) {
+	var dev devFS
+	read := func() []byte {
+		f, err := dev.Open("urandom")
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		defer f.Close()
+		b := make([]byte, 64)
+		if _, err := io.ReadFull(f, b); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return b
+	}
+	if bytes.Equal(read(), read()) {
+		t.Error("expected fresh bytes on every read")
+	}
+
+	entries, err := fs.ReadDir(dev, ".")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Name() != "urandom" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+	if _, err := dev.Open("null"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got: %v", err)
+	}
+}
+
+func TestRandomUUIDs(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	res, err := pg.QueryRows("SELECT gen_random_uuid()::text FROM generate_series(1, 1000);")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if len(res.Rows) != 1000 {
+		t.Fatalf("expected 1000 rows, got %d", len(res.Rows))
+	}
+	seen := make(map[string]bool)
+	for _, row := range res.Rows {
+		if seen[row[0]] {
+			t.Fatalf("duplicate UUID %s", row[0])
+		}
+		seen[row[0]] = true
+	}
+}
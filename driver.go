@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultDriver is the Driver registered as "pglite".
+var defaultDriver = &Driver{}
+
+func init() {
+	sql.Register("pglite", defaultDriver)
+}
+
+// Driver is a database/sql driver backed by PGLite, registered as "pglite".
+// The data source name is either the data directory (see Options.DataDir)
+// or, if it contains "=", a connection string parsed by ParseDSN.
+//
+// All connections opened with the same name share one PGLite instance,
+// which is started on first use and kept for the life of the process. The
+// instance has a single session, so transactions and session settings are
+// shared too; use sql.DB.SetMaxOpenConns(1) to avoid surprises.
+type Driver struct {
+	mu        sync.Mutex
+	instances map[string]*PGLite
+}
+
+// Open returns a connection to the instance for the named data directory,
+// starting the instance if needed.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if pg, ok := d.instances[name]; ok {
+		return &conn{pg: pg}, nil
+	}
+	var pg *PGLite
+	var err error
+	if strings.Contains(name, "=") {
+		pg, err = NewPGLiteDSN(context.Background(), name)
+	} else {
+		pg, err = NewPGLiteWithOptions(context.Background(), io.Discard, os.Stderr, Options{DataDir: name})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.instances == nil {
+		d.instances = make(map[string]*PGLite)
+	}
+	d.instances[name] = pg
+	return &conn{pg: pg}, nil
+}
+
+// conn is a driver.Conn on a shared PGLite instance.
+type conn struct {
+	pg *PGLite
+}
+
+var (
+	_ driver.QueryerContext = (*conn)(nil)
+	_ driver.ExecerContext  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+// Close is a no-op; the shared instance stays running.
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.pg.Begin()
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, err := bindArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.pg.queryRows(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{res: res}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, err := bindArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.pg.exec(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+// stmt is an unprepared statement; it interpolates its arguments and runs
+// its query on each call.
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+// bindArgs substitutes positional arguments into query.
+func bindArgs(query string, args []driver.NamedValue) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+	values := make([]any, len(args))
+	for _, a := range args {
+		if a.Name != "" {
+			return "", fmt.Errorf("pglite: named arguments: %w", ErrNotSupported)
+		}
+		values[a.Ordinal-1] = a.Value
+	}
+	return interpolate(query, values)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// rows iterates over a fully read Result.
+type rows struct {
+	res *Result
+	i   int
+}
+
+func (r *rows) Columns() []string {
+	return r.res.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.Rows) {
+		return io.EOF
+	}
+	for j, v := range r.res.Rows[r.i] {
+		switch {
+		case r.res.IsNull(r.i, j):
+			dest[j] = nil
+		case r.res.Types[j] == byteaOID:
+			b, err := DecodeBytea(v)
+			if err != nil {
+				return err
+			}
+			dest[j] = b
+		default:
+			dest[j] = v
+		}
+	}
+	r.i++
+	return nil
+}
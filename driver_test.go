@@ -0,0 +1,47 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestDriver(t *testing.T) {
+	db, err := sql.Open("pglite", t.TempDir())
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE driver_test (id integer, name text);"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO driver_test VALUES ($1, $2), ($3, $4);", 1, "one", 2, nil); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rows, err := db.Query("SELECT id, name FROM driver_test ORDER BY id;")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id int
+		var name sql.NullString
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, name.String)
+		if id == 2 && name.Valid {
+			t.Errorf("expected NULL name for id 2, got %q", name.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows: %v", err)
+	}
+	if len(got) != 2 || got[0] != "one" {
+		t.Errorf("unexpected rows: %q", got)
+	}
+}
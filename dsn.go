@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// NewPGLiteDSN is like NewPGLiteWithOptions but takes its configuration
+// from dsn, parsed by ParseDSN. PostgreSQL's output is discarded, apart
+// from that written to stderr, as for the database/sql driver.
+func NewPGLiteDSN(ctx context.Context, dsn string) (*PGLite, error) {
+	opts, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPGLiteWithOptions(ctx, io.Discard, os.Stderr, opts)
+}
+
+// ParseDSN returns the Options described by dsn, a libpq-style connection
+// string of space-separated keyword=value settings, such as
+//
+//	datadir=/var/lib/app dbname=app user=admin options='-c work_mem=64MB'
+//
+// A value may be single-quoted, to hold spaces, with \' and \\ standing
+// for a quote and a backslash. The keywords are:
+//
+//	datadir  the data directory (Options.DataDir)
+//	dbname   the database (Options.Database)
+//	user     the user (Options.User)
+//	options  server options (Options.ExtraArgs), separated by spaces,
+//	         with \ escaping a space, written \\ inside quotes
+//
+// Other keywords, including libpq's host and port, which have no meaning
+// for an embedded instance, are errors.
+func ParseDSN(dsn string) (Options, error) {
+	var opts Options
+	s := dsn
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return opts, nil
+		}
+		key, rest, ok := strings.Cut(s, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsFunc(key, unicode.IsSpace) {
+			return Options{}, fmt.Errorf("dsn: missing \"=\" after %q", strings.Fields(s)[0])
+		}
+		value, rest, err := dsnValue(strings.TrimLeftFunc(rest, unicode.IsSpace))
+		if err != nil {
+			return Options{}, fmt.Errorf("dsn: %s: %w", key, err)
+		}
+		s = rest
+
+		switch key {
+		case "datadir":
+			opts.DataDir = value
+		case "dbname":
+			opts.Database = value
+		case "user":
+			opts.User = value
+		case "options":
+			opts.ExtraArgs = splitOptions(value)
+		default:
+			return Options{}, fmt.Errorf("dsn: unknown keyword %q", key)
+		}
+	}
+}
+
+// dsnValue reads a value, quoted or not, from the start of s and returns
+// it and the rest of s.
+func dsnValue(s string) (value, rest string, err error) {
+	if !strings.HasPrefix(s, "'") {
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:], nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			return b.String(), s[i+1:], nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated quoted value")
+}
+
+// splitOptions splits the value of the options keyword into arguments, at
+// spaces not escaped with a backslash.
+func splitOptions(s string) []string {
+	var args []string
+	var b strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+			inArg = true
+		case unicode.IsSpace(rune(c)):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args
+}
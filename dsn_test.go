@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want Options
+	}{
+		{"", Options{}},
+		{"datadir=/tmp/db dbname=app user=admin", Options{DataDir: "/tmp/db", Database: "app", User: "admin"}},
+		{"  datadir = '/tmp/my db'  ", Options{DataDir: "/tmp/my db"}},
+		{`dbname='it\'s' user=''`, Options{Database: "it's"}},
+		{`options='-c work_mem=64MB  -c application_name=a\\ b'`,
+			Options{ExtraArgs: []string{"-c", "work_mem=64MB", "-c", "application_name=a b"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseDSN(tt.dsn)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDSN(%q): got %+v, %v, want %+v", tt.dsn, got, err, tt.want)
+		}
+	}
+
+	for _, dsn := range []string{
+		"host=localhost",
+		"datadir",
+		"datadir=/tmp dbname",
+		"dbname='open",
+	} {
+		if _, err := ParseDSN(dsn); err == nil {
+			t.Errorf("ParseDSN(%q): expected an error", dsn)
+		}
+	}
+}
+
+func TestDriverDSN(t *testing.T) {
+	db, err := sql.Open("pglite", "datadir='"+t.TempDir()+"' options='-c work_mem=8MB'")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	var mem string
+	if err := db.QueryRow("SHOW work_mem;").Scan(&mem); err != nil || mem != "8MB" {
+		t.Errorf("work_mem: got %q, %v", mem, err)
+	}
+
+	bad, err := sql.Open("pglite", "datadir=x port=5432")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer bad.Close()
+	if err := bad.Ping(); err == nil {
+		t.Error("expected an error for an unknown keyword")
+	}
+}
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// userRelations selects the tables, views and sequences created by the
+// user, leaving out the system catalogs.
+const userRelations = `SELECT c.oid FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'`
+
+// DumpSQL writes a SQL script that recreates the user schemas, extensions,
+// types, sequences, functions, tables, partitions, constraints, views,
+// materialized views, indexes and triggers of the current database, along
+// with the table data, the privileges granted on tables, views and
+// sequences, and the comments on all of these, to w. The script can be
+// loaded back with RunQueries or Restore. Types are the enums, domains,
+// composite and range types the user created; those of extensions come
+// with their extension, which must be available where the script is
+// loaded.
+//
+// Owners and the privileges on other objects are not written, and the
+// roles granted privileges must exist where the script is loaded. A
+// database with row security policies or rewrite rules, which the script
+// cannot recreate, is not dumped; DumpSQL returns an error instead.
+//
+// The embedded build has no pg_dump, so the script is built from the
+// system catalogs. Rows are written in multi-row INSERT statements, each
+// short enough to be sent to the backend as one message when loaded; see
+// ErrQueryTooLarge. A row too long for that has its longer values built
+// up in a custom setting, pglite.dump_value_N, a piece per statement,
+// before it is inserted. The dump is taken with several queries;
+// concurrent writers may make it inconsistent.
+func (p *PGLite) DumpSQL(w io.Writer) error {
+	d := &dumper{p: p, w: w}
+	d.printf("-- PGLite database dump\n\nSET check_function_bodies = false;\n")
+	for _, section := range []func() error{
+		d.unsupported,
+		d.schemas,
+		d.extensions,
+		d.types,
+		d.sequences,
+		d.functions,
+		d.tables,
+		d.constraints,
+		d.views,
+		d.indexes,
+		d.triggers,
+		d.grants,
+		d.comments,
+		d.sequenceValues,
+	} {
+		if err := section(); err != nil {
+			return fmt.Errorf("dump: %w", err)
+		}
+		if d.err != nil {
+			return fmt.Errorf("dump: %w", d.err)
+		}
+	}
+	d.printf("\nRESET check_function_bodies;\n")
+	return d.err
+}
+
+// dumper writes a dump, remembering the first write error.
+type dumper struct {
+	p   *PGLite
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...any) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// unsupported reports the objects the dump cannot recreate.
+func (d *dumper) unsupported() error {
+	res, err := d.p.QueryRows(`SELECT 'row security policy ' || quote_ident(polname) || ' on ' || polrelid::regclass::text
+FROM pg_policy WHERE polrelid IN (` + userRelations + `)
+UNION ALL
+SELECT 'rule ' || quote_ident(rulename) || ' on ' || ev_class::regclass::text
+FROM pg_rewrite WHERE ev_class IN (` + userRelations + `) AND rulename <> '_RETURN'
+LIMIT 1;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		return fmt.Errorf("%s: %w", res.Rows[0][0], ErrNotSupported)
+	}
+	return nil
+}
+
+func (d *dumper) schemas() error {
+	res, err := d.p.QueryRows(`SELECT quote_ident(nspname) FROM pg_namespace
+WHERE nspname NOT IN ('pg_catalog', 'information_schema', 'public') AND nspname NOT LIKE 'pg\_%'
+ORDER BY nspname;`)
+	if err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		d.printf("\nCREATE SCHEMA %s;\n", row[0])
+	}
+	return nil
+}
+
+// extensions writes the extensions installed, apart from plpgsql, which
+// every database has.
+func (d *dumper) extensions() error {
+	res, err := d.p.QueryRows(`SELECT quote_ident(e.extname), quote_ident(n.nspname) FROM pg_extension e
+JOIN pg_namespace n ON n.oid = e.extnamespace
+WHERE e.extname <> 'plpgsql'
+ORDER BY e.oid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("CREATE EXTENSION IF NOT EXISTS %s WITH SCHEMA %s;\n", row[0], row[1])
+	}
+	return nil
+}
+
+// types writes the enums, domains, standalone composite types and range
+// types not created by an extension, in the order they were created, so
+// that each follows the types it is built on.
+func (d *dumper) types() error {
+	res, err := d.p.QueryRows(`SELECT CASE t.typtype
+WHEN 'e' THEN 'CREATE TYPE ' || format_type(t.oid, NULL) || ' AS ENUM (' || coalesce((SELECT
+	string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder)
+	FROM pg_enum e WHERE e.enumtypid = t.oid), '') || ')'
+WHEN 'd' THEN 'CREATE DOMAIN ' || format_type(t.oid, NULL) || ' AS ' || format_type(t.typbasetype, t.typtypmod)
+	|| coalesce(' DEFAULT ' || t.typdefault, '')
+	|| CASE WHEN t.typnotnull THEN ' NOT NULL' ELSE '' END
+	|| coalesce((SELECT string_agg(' CONSTRAINT ' || quote_ident(c.conname) || ' ' || pg_get_constraintdef(c.oid), '' ORDER BY c.conname)
+	FROM pg_constraint c WHERE c.contypid = t.oid AND c.contype = 'c'), '')
+WHEN 'c' THEN 'CREATE TYPE ' || format_type(t.oid, NULL) || ' AS (' || coalesce((SELECT
+	string_agg(quote_ident(a.attname) || ' ' || format_type(a.atttypid, a.atttypmod), ', ' ORDER BY a.attnum)
+	FROM pg_attribute a WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped), '') || ')'
+WHEN 'r' THEN 'CREATE TYPE ' || format_type(t.oid, NULL) || ' AS RANGE (SUBTYPE = ' || (SELECT
+	format_type(r.rngsubtype, NULL) FROM pg_range r WHERE r.rngtypid = t.oid) || ')'
+END
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+LEFT JOIN pg_class c ON c.oid = t.typrelid
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+AND (t.typtype IN ('e', 'd', 'r') OR t.typtype = 'c' AND c.relkind = 'c')
+AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = t.oid AND d.deptype = 'e')
+ORDER BY t.oid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("%s;\n", row[0])
+	}
+	return nil
+}
+
+// sequences writes the sequences that are not part of an identity column;
+// those are created along with their table.
+func (d *dumper) sequences() error {
+	res, err := d.p.QueryRows(`SELECT s.seqrelid::regclass::text, format_type(s.seqtypid, NULL),
+s.seqincrement, s.seqmin, s.seqmax, s.seqstart, s.seqcache, s.seqcycle
+FROM pg_sequence s WHERE s.seqrelid IN (` + userRelations + `)
+AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = s.seqrelid AND d.deptype = 'i')
+ORDER BY s.seqrelid;`)
+	if err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		cycle := ""
+		if row[7] == "t" {
+			cycle = " CYCLE"
+		}
+		d.printf("\nCREATE SEQUENCE %s AS %s INCREMENT BY %s MINVALUE %s MAXVALUE %s START WITH %s CACHE %s%s;\n",
+			row[0], row[1], row[2], row[3], row[4], row[5], row[6], cycle)
+	}
+	return nil
+}
+
+func (d *dumper) functions() error {
+	res, err := d.p.QueryRows(`SELECT pg_get_functiondef(p.oid) FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+AND p.prokind IN ('f', 'p')
+AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')
+ORDER BY p.oid;`)
+	if err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		d.printf("\n%s;\n", strings.TrimRight(row[0], "\n"))
+	}
+	return nil
+}
+
+// dumpColumn is a column of a dumped table.
+type dumpColumn struct {
+	name      string // quoted
+	typ       string // type, as format_type gives it
+	def       string // column definition for CREATE TABLE
+	generated bool   // computed, so left out of INSERT statements
+	always    bool   // GENERATED ALWAYS AS IDENTITY
+}
+
+// dumpTable is a dumped table.
+type dumpTable struct {
+	name    string
+	columns []dumpColumn
+	partKey string // PARTITION BY clause of a partitioned table
+	parent  string // the table a partition belongs to
+	bound   string // FOR VALUES clause of a partition
+	hasRows bool   // not partitioned, so holding rows of its own
+}
+
+// tables writes each table followed by its rows. Constraints are added
+// once all rows are loaded, so foreign keys cannot fail on load order.
+// A partitioned table comes before its partitions, which take their
+// columns from it, and its rows are written through the partitions that
+// hold them.
+func (d *dumper) tables() error {
+	res, err := d.p.QueryRows(`SELECT a.attrelid::regclass::text, quote_ident(a.attname),
+format_type(a.atttypid, a.atttypmod), a.attnotnull, pg_get_expr(ad.adbin, ad.adrelid),
+a.attidentity, a.attgenerated, c.relkind, pg_get_partkeydef(c.oid),
+(SELECT i.inhparent::regclass::text FROM pg_inherits i WHERE i.inhrelid = c.oid AND c.relispartition),
+pg_get_expr(c.relpartbound, c.oid)
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+WHERE a.attrelid IN (` + userRelations + `) AND c.relkind IN ('r', 'p')
+AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY (SELECT count(*) FROM pg_partition_ancestors(c.oid)), a.attrelid, a.attnum;`)
+	if err != nil {
+		return err
+	}
+
+	var tables []*dumpTable
+	for _, row := range res.Rows {
+		name, expr := row[0], row[4]
+		col := dumpColumn{name: row[1], typ: row[2], def: row[1] + " " + row[2]}
+		switch {
+		case row[6] == "s":
+			col.def += " GENERATED ALWAYS AS (" + expr + ") STORED"
+			col.generated = true
+		case row[5] == "a":
+			col.def += " GENERATED ALWAYS AS IDENTITY"
+			col.always = true
+		case row[5] == "d":
+			col.def += " GENERATED BY DEFAULT AS IDENTITY"
+		case expr != "":
+			col.def += " DEFAULT " + expr
+		}
+		if row[3] == "t" {
+			col.def += " NOT NULL"
+		}
+		if len(tables) == 0 || tables[len(tables)-1].name != name {
+			tables = append(tables, &dumpTable{
+				name:    name,
+				partKey: row[8],
+				parent:  row[9],
+				bound:   row[10],
+				hasRows: row[7] == "r",
+			})
+		}
+		t := tables[len(tables)-1]
+		t.columns = append(t.columns, col)
+	}
+
+	for _, t := range tables {
+		partition := ""
+		if t.partKey != "" {
+			partition = " PARTITION BY " + t.partKey
+		}
+		if t.parent != "" {
+			d.printf("\nCREATE TABLE %s PARTITION OF %s %s%s;\n", t.name, t.parent, t.bound, partition)
+		} else {
+			defs := make([]string, len(t.columns))
+			for i, col := range t.columns {
+				defs[i] = "    " + col.def
+			}
+			d.printf("\nCREATE TABLE %s (\n%s\n)%s;\n", t.name, strings.Join(defs, ",\n"), partition)
+		}
+		if !t.hasRows {
+			continue
+		}
+		if err := d.rows(t.name, t.columns); err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// dumpStatementSize bounds the statements rows writes, so that each fits
+// in a Query message when loaded.
+const dumpStatementSize = maxQuerySize - 1
+
+// dumpInlineValue is the longest value left in place when a row is too
+// long for one statement; longer ones are set aside by setValue.
+const dumpInlineValue = 64
+
+// rows writes the rows of table as INSERT statements, as many rows to a
+// statement as fit in dumpStatementSize. Values are read as quoted text
+// literals, which the columns convert back on insert, a row at a time, so
+// that a large table is never held in memory.
+func (d *dumper) rows(table string, cols []dumpColumn) error {
+	var (
+		names, values []string
+		types         []string
+		override      string
+	)
+	for _, col := range cols {
+		if col.generated {
+			continue
+		}
+		names = append(names, col.name)
+		types = append(types, col.typ)
+		values = append(values, "quote_nullable("+col.name+"::text)")
+		if col.always {
+			override = " OVERRIDING SYSTEM VALUE"
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	insert := "INSERT INTO " + table + " (" + strings.Join(names, ", ") + ")" + override + " VALUES"
+	var batch []string
+	size := 0
+	flush := func() {
+		if len(batch) > 0 {
+			d.printf("%s\n%s;\n", insert, strings.Join(batch, ",\n"))
+			batch, size = batch[:0], 0
+		}
+	}
+	first := true
+	err := d.p.QueryEach("SELECT "+strings.Join(values, ", ")+" FROM "+table+";", func(row []string) error {
+		if first {
+			d.printf("\n")
+			first = false
+		}
+		tuple := "(" + strings.Join(row, ", ") + ")"
+		if len(insert)+1+len(tuple)+1 > dumpStatementSize {
+			flush()
+			return d.longRow(insert, row, types)
+		}
+		if len(batch) > 0 && len(insert)+size+2+len(tuple)+1 > dumpStatementSize {
+			flush()
+		}
+		if len(batch) > 0 {
+			size += 2
+		}
+		batch = append(batch, tuple)
+		size += 1 + len(tuple)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flush()
+	return nil
+}
+
+// longRow writes a row too long for one statement: each value longer
+// than dumpInlineValue is put together in a setting first, and the row
+// then inserted with the settings in their place.
+func (d *dumper) longRow(insert string, row, types []string) error {
+	vals := make([]string, len(row))
+	var set []string
+	for i, lit := range row {
+		if len(lit) <= dumpInlineValue || lit == "NULL" {
+			vals[i] = lit
+			continue
+		}
+		name := fmt.Sprintf("pglite.dump_value_%d", i+1)
+		if err := d.setValue(name, unquoteLiteral(lit)); err != nil {
+			return err
+		}
+		vals[i] = "pg_catalog.current_setting('" + name + "')::" + types[i]
+		set = append(set, name)
+	}
+	d.printf("%s (%s);\n", insert, strings.Join(vals, ", "))
+	for _, name := range set {
+		d.printf("RESET %s;\n", name)
+	}
+	return nil
+}
+
+// setValue writes statements that set the custom setting name to v, a
+// piece at a time.
+func (d *dumper) setValue(name, v string) error {
+	// Quoting at most doubles a piece.
+	const piece = (dumpStatementSize - 200) / 2
+	for first := true; v != ""; first = false {
+		n := min(piece, len(v))
+		for n < len(v) && !utf8.RuneStart(v[n]) {
+			n--
+		}
+		value, err := quoteString(v[:n])
+		if err != nil {
+			return err
+		}
+		if !first {
+			value = "pg_catalog.current_setting('" + name + "') || " + value
+		}
+		d.printf("SELECT FROM pg_catalog.set_config('%s', %s, false);\n", name, value)
+		v = v[n:]
+	}
+	return nil
+}
+
+// unquoteLiteral reverses quote_literal: it returns the string a quoted
+// literal stands for, undoing the doubling of quotes and, in a literal
+// with the E prefix, of backslashes.
+func unquoteLiteral(lit string) string {
+	escaped := strings.HasPrefix(lit, "E'")
+	lit = strings.TrimPrefix(lit, "E")
+	lit = strings.ReplaceAll(lit[1:len(lit)-1], "''", "'")
+	if escaped {
+		lit = strings.ReplaceAll(lit, `\\`, `\`)
+	}
+	return lit
+}
+
+// constraints writes the table constraints, foreign keys last, and the
+// columns that own serial sequences. Those a partition has from its
+// partitioned table come with the table's.
+func (d *dumper) constraints() error {
+	res, err := d.p.QueryRows(`SELECT con.conrelid::regclass::text, quote_ident(con.conname), pg_get_constraintdef(con.oid)
+FROM pg_constraint con JOIN pg_class c ON c.oid = con.conrelid
+WHERE con.conrelid IN (` + userRelations + `)
+AND con.conparentid = 0 AND (con.conislocal OR NOT c.relispartition)
+ORDER BY con.contype = 'f', con.conrelid, con.conname;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("ALTER TABLE %s ADD CONSTRAINT %s %s;\n", row[0], row[1], row[2])
+	}
+
+	res, err = d.p.QueryRows(`SELECT d.objid::regclass::text, d.refobjid::regclass::text, quote_ident(a.attname)
+FROM pg_depend d
+JOIN pg_sequence s ON s.seqrelid = d.objid
+JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+WHERE d.classid = 'pg_class'::regclass AND d.deptype = 'a' AND d.objid IN (` + userRelations + `)
+ORDER BY d.objid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("ALTER SEQUENCE %s OWNED BY %s.%s;\n", row[0], row[1], row[2])
+	}
+	return nil
+}
+
+// indexes writes the indexes that do not back a constraint, those of
+// materialized views included. An index on a partitioned table is
+// written without ONLY, so that it is created on the partitions too, and
+// theirs are left out.
+func (d *dumper) indexes() error {
+	res, err := d.p.QueryRows(`SELECT pg_get_indexdef(i.indexrelid) FROM pg_index i
+WHERE i.indrelid IN (` + userRelations + `)
+AND NOT EXISTS (SELECT 1 FROM pg_constraint c
+	WHERE c.conindid = i.indexrelid AND c.contype IN ('p', 'u', 'x'))
+AND NOT EXISTS (SELECT 1 FROM pg_inherits h WHERE h.inhrelid = i.indexrelid)
+ORDER BY i.indexrelid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("%s;\n", strings.Replace(row[0], " ON ONLY ", " ON ", 1))
+	}
+	return nil
+}
+
+// views writes the views and materialized views, in the order they were
+// created, so that each follows those it selects from. Materialized views
+// are filled as they are created, from the rows already loaded.
+func (d *dumper) views() error {
+	res, err := d.p.QueryRows(`SELECT c.oid::regclass::text, c.relkind, pg_get_viewdef(c.oid) FROM pg_class c
+WHERE c.oid IN (` + userRelations + `) AND c.relkind IN ('v', 'm')
+ORDER BY c.oid;`)
+	if err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		kind := "VIEW"
+		if row[1] == "m" {
+			kind = "MATERIALIZED VIEW"
+		}
+		d.printf("\nCREATE %s %s AS\n%s\n", kind, row[0], row[2])
+	}
+	return nil
+}
+
+// triggers writes the user triggers, once the rows are loaded so that
+// loading them fires none. Those a partition has from its partitioned
+// table come with the table's.
+func (d *dumper) triggers() error {
+	res, err := d.p.QueryRows(`SELECT pg_get_triggerdef(t.oid) FROM pg_trigger t
+WHERE t.tgrelid IN (` + userRelations + `) AND NOT t.tgisinternal AND t.tgparentid = 0
+ORDER BY t.tgrelid, t.tgname;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		d.printf("%s;\n", row[0])
+	}
+	return nil
+}
+
+// grants writes the privileges granted on tables, views and sequences to
+// roles other than their owner. The roles must exist where the dump is
+// loaded.
+func (d *dumper) grants() error {
+	res, err := d.p.QueryRows(`SELECT c.oid::regclass::text, c.relkind, a.privilege_type,
+CASE a.grantee WHEN 0 THEN 'PUBLIC' ELSE quote_ident(pg_get_userbyid(a.grantee)) END, a.is_grantable
+FROM pg_class c, aclexplode(c.relacl) a
+WHERE c.oid IN (` + userRelations + `) AND a.grantee <> c.relowner
+ORDER BY c.oid, a.grantee, a.privilege_type;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		kind := "TABLE"
+		if row[1] == "S" {
+			kind = "SEQUENCE"
+		}
+		option := ""
+		if row[4] == "t" {
+			option = " WITH GRANT OPTION"
+		}
+		d.printf("GRANT %s ON %s %s TO %s%s;\n", row[2], kind, row[0], row[3], option)
+	}
+	return nil
+}
+
+// comments writes the comments on the objects the user created, those
+// with an object ID past the ones initdb assigns.
+func (d *dumper) comments() error {
+	res, err := d.p.QueryRows(`SELECT o.type, o.identity, quote_literal(d.description)
+FROM pg_description d, pg_identify_object(d.classoid, d.objoid, d.objsubid) o
+WHERE d.objoid >= 16384
+AND NOT EXISTS (SELECT 1 FROM pg_depend e
+	WHERE e.classid = d.classoid AND e.objid = d.objoid AND e.deptype = 'e')
+ORDER BY d.classoid, d.objoid, d.objsubid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		kind, object := strings.ToUpper(row[0]), row[1]
+		switch row[0] {
+		case "table column":
+			kind = "COLUMN"
+		case "table constraint":
+			kind = "CONSTRAINT"
+		case "domain constraint":
+			kind = "CONSTRAINT"
+			object = strings.Replace(object, " on ", " ON DOMAIN ", 1)
+		case "composite type":
+			kind = "TYPE"
+		}
+		d.printf("COMMENT ON %s %s IS %s;\n", kind, object, row[2])
+	}
+	return nil
+}
+
+// sequenceValues writes the current position of every sequence, including
+// those of identity columns.
+func (d *dumper) sequenceValues() error {
+	res, err := d.p.QueryRows(`SELECT seqrelid::regclass::text FROM pg_sequence
+WHERE seqrelid IN (` + userRelations + `) ORDER BY seqrelid;`)
+	if err != nil {
+		return err
+	}
+	if len(res.Rows) > 0 {
+		d.printf("\n")
+	}
+	for _, row := range res.Rows {
+		seq := row[0]
+		val, err := d.p.QueryRows("SELECT last_value, is_called FROM " + seq + ";")
+		if err != nil {
+			return err
+		}
+		if len(val.Rows) != 1 {
+			return fmt.Errorf("%s: no sequence state", seq)
+		}
+		name, err := quoteString(seq)
+		if err != nil {
+			return err
+		}
+		called := "false"
+		if val.Rows[0][1] == "t" {
+			called = "true"
+		}
+		d.printf("SELECT pg_catalog.setval(%s, %s, %s);\n", name, val.Rows[0][0], called)
+	}
+	return nil
+}
+
+// RestoreError reports the statement that made Restore fail.
+type RestoreError struct {
+	Statement string
+	Err       error
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("restore: %v\nstatement: %s", e.Err, e.Statement)
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+// Restore drops all user schemas and the objects in them, recreates an
+// empty public schema and then runs the SQL script read from r, such as one
+// written by DumpSQL. It all happens in one transaction: if a statement
+// fails, the database is rolled back to its state before the call and a
+// *RestoreError holding the statement is returned. The script must not
+// contain statements that cannot run in a transaction block.
+func (p *PGLite) Restore(r io.Reader) error {
+	tx, err := p.Begin()
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	if stmt, err := p.dropUserSchemas(); err != nil {
+		return &RestoreError{Statement: stmt, Err: err}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxStatementSize)
+	sc.Split(scanStatements)
+	for sc.Scan() {
+		if stmt := sc.Text(); stmt != "" {
+			if err := p.Query(stmt); err != nil {
+				return &RestoreError{Statement: stmt, Err: err}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Reset drops all user schemas and everything in them, including tables,
+// sequences, functions and any extensions installed in them, recreates an
+// empty public schema and drops the session's temporary tables. It then
+// recreates the extensions in Options.Extensions. Roles, databases and
+// session settings are kept, as is plpgsql, which lives in pg_catalog. The
+// instance keeps running, so this is much faster than starting a new one.
+// It all happens in one transaction.
+func (p *PGLite) Reset() error {
+	tx, err := p.Begin()
+	if err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := p.dropUserSchemas(); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	if err := p.Query("DISCARD TEMP;"); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	return p.createExtensions(p.opts.Extensions)
+}
+
+// dropUserSchemas drops the user schemas and recreates an empty public
+// schema. If a statement fails, it returns the statement and error.
+func (p *PGLite) dropUserSchemas() (string, error) {
+	q := `SELECT quote_ident(nspname) FROM pg_namespace
+WHERE nspname NOT IN ('pg_catalog', 'information_schema') AND nspname NOT LIKE 'pg\_%'
+ORDER BY nspname;`
+	res, err := p.QueryRows(q)
+	if err != nil {
+		return q, err
+	}
+	stmts := make([]string, 0, len(res.Rows)+3)
+	for _, row := range res.Rows {
+		stmts = append(stmts, "DROP SCHEMA "+row[0]+" CASCADE;")
+	}
+	stmts = append(stmts,
+		"CREATE SCHEMA public;",
+		"ALTER SCHEMA public OWNER TO pg_database_owner;",
+		"GRANT USAGE ON SCHEMA public TO PUBLIC;",
+	)
+	for _, stmt := range stmts {
+		if err := p.Query(stmt); err != nil {
+			return stmt, err
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,11 @@
+//go:build !pglite_noembed
+
+package main
+
+import _ "embed"
+
+// compressed is the installation tarball, embedded unless the program is
+// built with the pglite_noembed tag.
+//
+//go:embed pglite-wasi.tar.gz
+var compressed []byte
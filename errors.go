@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrTruncated is matched by a TruncatedError.
+var ErrTruncated = errors.New("query output truncated")
+
+// ErrNotSupported is wrapped by the errors of operations that cannot work
+// on this instance, with the reason, so that callers can test for it with
+// errors.Is. It stands for limits of PGLite rather than faults in the SQL:
+//
+//   - COPY ... TO PROGRAM and FROM PROGRAM: the WASI build cannot start
+//     processes.
+//   - CREATE SUBSCRIPTION: PostgreSQL runs in single-user mode, with no
+//     background workers to receive logical replication, and the backend
+//     would trap.
+//   - Cancel requests sent to Serve: a statement runs to completion; see
+//     QueryContext for a way to stop one.
+//   - Named arguments given to the database/sql driver.
+//   - InMemory and TempDir with ReadOnly, or with NewPGLiteFromWASM.
+//   - DumpSQL of a database with row security policies or rewrite rules.
+//
+// The statements are refused before they reach the backend, by Query and
+// the other methods that run SQL, but not when sent by a client of Serve.
+var ErrNotSupported = errors.New("not supported")
+
+// QueryError is an error reported by PostgreSQL while running a query.
+type QueryError struct {
+	Severity string // ERROR, FATAL or PANIC
+	Code     string // SQLSTATE, such as "42P01"
+	Message  string
+	Detail   string
+	Hint     string
+	Position int // 1-based character in the query where the error is, or 0
+}
+
+func (e *QueryError) Error() string {
+	s := e.Severity + ": " + e.Message
+	if e.Code != "" {
+		s += " (SQLSTATE " + e.Code + ")"
+	}
+	return s
+}
+
+// parseErrorResponse converts an ErrorResponse message into a QueryError.
+func parseErrorResponse(body []byte) *QueryError {
+	qerr := &QueryError{}
+	for len(body) > 0 && body[0] != 0 {
+		end := bytes.IndexByte(body[1:], 0)
+		if end < 0 {
+			break
+		}
+		v := string(body[1 : 1+end])
+		switch body[0] {
+		case 'V':
+			qerr.Severity = v
+		case 'S':
+			if qerr.Severity == "" {
+				qerr.Severity = v
+			}
+		case 'C':
+			qerr.Code = v
+		case 'M':
+			qerr.Message = v
+		case 'D':
+			qerr.Detail = v
+		case 'H':
+			qerr.Hint = v
+		case 'P':
+			qerr.Position, _ = strconv.Atoi(v)
+		}
+		body = body[2+end:]
+	}
+	// The backend has no exception stack to return to, so it promotes
+	// each ERROR to FATAL and exits, and the session is restarted; see
+	// send. Those are reported as the ERRORs they were.
+	if qerr.Severity == "FATAL" && !fatalOnly(qerr.Code) {
+		qerr.Severity = "ERROR"
+	}
+	return qerr
+}
+
+// fatalOnly reports whether PostgreSQL raises errors with the SQLSTATE
+// code only at FATAL, so that a FATAL error with it is not a promoted
+// ERROR: authorization failures, a missing database and the shutdown and
+// connection errors of class 57P, as when a session cannot start or is
+// terminated, and protocol violations. Without a code, it cannot tell,
+// and reports true. Out of memory (53200) is raised at either level, but
+// nearly always at ERROR while a statement runs, so it reports false.
+func fatalOnly(code string) bool {
+	switch {
+	case code == "", strings.HasPrefix(code, "28"), strings.HasPrefix(code, "57P"):
+		return true
+	}
+	switch code {
+	case "3D000", "08P01":
+		return true
+	}
+	return false
+}
+
+// StartError is returned when an instance fails to start. Log holds the
+// end of what the module printed while starting, which usually says why.
+type StartError struct {
+	Err error
+	Log string
+}
+
+func (e *StartError) Error() string {
+	log := strings.TrimSpace(e.Log)
+	if log == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + "\nstartup log:\n" + log
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError is returned when the backend's reply to a query ends
+// before the query completes, so any rows returned are incomplete. Rows
+// is the number of rows read before the reply ended. Err, if not nil, is
+// the error from reading the last, partial message.
+type TruncatedError struct {
+	Rows int
+	Err  error
+}
+
+func (e *TruncatedError) Error() string {
+	s := fmt.Sprintf("%v after %d rows", ErrTruncated, e.Rows)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorResponse(t *testing.T) {
+	body := []byte("SFATAL\x00VFATAL\x00C42703\x00Mcolumn \"y\" does not exist\x00" +
+		"Dfirst line\nsecond line\x00HPerhaps you meant \"x\".\x00P8\x00" +
+		"Fparse_relation.c\x00L3720\x00RerrorMissingColumn\x00\x00")
+	want := QueryError{
+		Severity: "ERROR",
+		Code:     "42703",
+		Message:  `column "y" does not exist`,
+		Detail:   "first line\nsecond line",
+		Hint:     `Perhaps you meant "x".`,
+		Position: 8,
+	}
+	if got := parseErrorResponse(body); *got != want {
+		t.Errorf("got %+v\nwant %+v", *got, want)
+	}
+
+	// A FATAL error PostgreSQL raises only at that level is kept.
+	fatal := parseErrorResponse([]byte("SFATAL\x00VFATAL\x00C3D000\x00Mdatabase \"x\" does not exist\x00\x00"))
+	if fatal.Severity != "FATAL" || fatal.Code != "3D000" {
+		t.Errorf("fatal parsed as %+v", *fatal)
+	}
+
+	notice := parseErrorResponse([]byte("SWARNING\x00VWARNING\x00C01000\x00Mcareful\x00\x00"))
+	if notice.Severity != "WARNING" || notice.Message != "careful" {
+		t.Errorf("notice parsed as %+v", *notice)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	err := testPG.Query("SELECT * FROM nonexistent;")
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+	if qerr.Code != "42P01" {
+		t.Errorf("Code = %q, want 42P01 (%v)", qerr.Code, qerr)
+	}
+
+	if err := testPG.Query("SELECT 1;"); err != nil {
+		t.Errorf("query after error: %v", err)
+	}
+}
+
+func TestStartError(t *testing.T) {
+	dir := t.TempDir()
+	if err := ExtractEnv(dir); err != nil {
+		t.Fatalf("ExtractEnv: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tmp/pglite/base/PG_VERSION"), []byte("9.6\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("expected StartError, got: %v", err)
+	}
+	if !strings.Contains(startErr.Log, "incompatible") {
+		t.Errorf("expected the startup log to explain the failure, got:\n%s", startErr.Log)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var tb tailBuffer
+	tb.Write(bytes.Repeat([]byte("a"), tailBufferSize))
+	tb.Write([]byte("end"))
+	if len(tb.String()) != tailBufferSize || !strings.HasSuffix(tb.String(), "aend") {
+		t.Errorf("unexpected tail: %d bytes ending %q", len(tb.String()), tb.String()[len(tb.String())-4:])
+	}
+}
+
+func TestReadReplyTruncated(t *testing.T) {
+	p := &PGLite{dataDir: t.TempDir()}
+	out := filepath.Join(p.dataDir, sockOut)
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	reply := appendMessage(nil, 'D', []byte{0, 1, 0, 0, 0, 1, '1'})
+	reply = appendMessage(reply, 'D', []byte{0, 1, 0, 0, 0, 1, '2'})
+	if err := os.WriteFile(out, reply[:len(reply)-3], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	err := p.readReply(func(byte, []byte) error {
+		n++
+		return nil
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || n != 1 {
+		t.Errorf("got %d messages, %v", n, err)
+	}
+
+	terr := error(&TruncatedError{Rows: n, Err: err})
+	if !errors.Is(terr, ErrTruncated) || !errors.Is(terr, io.ErrUnexpectedEOF) {
+		t.Errorf("%v does not match ErrTruncated and its cause", terr)
+	}
+	if !strings.Contains(terr.Error(), "after 1 rows") {
+		t.Errorf("unexpected message: %v", terr)
+	}
+}
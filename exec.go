@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommandResult describes the outcome of a statement run by Exec.
+type CommandResult struct {
+	Command      string // for example "INSERT" or "CREATE TABLE"
+	RowsAffected int64
+}
+
+// Exec executes a single SQL statement and reports the command it ran and
+// the number of rows it affected or returned, both from the command tag
+// the backend sends once it completes, such as "INSERT 0 5". Statements
+// without a row count, such as CREATE TABLE, report zero. If sql holds
+// several statements, the last one's tag is reported.
+func (p *PGLite) Exec(sql string) (CommandResult, error) {
+	return p.exec(p.ctx, sql)
+}
+
+func (p *PGLite) exec(ctx context.Context, sql string) (CommandResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tag := &commandTag{}
+	if err := p.query(ctx, sql, nil, tag); err != nil {
+		return CommandResult{Command: commandName(sqlWords(sql))}, err
+	}
+	if tag.tag == "" {
+		return CommandResult{Command: commandName(sqlWords(sql))}, nil
+	}
+	return parseCommandTag(tag.tag), nil
+}
+
+// parseCommandTag splits a command tag into the command and the row
+// count that ends it, if any.
+func parseCommandTag(tag string) CommandResult {
+	var res CommandResult
+	words := strings.Fields(tag)
+	if len(words) > 1 {
+		if n, err := strconv.ParseInt(words[len(words)-1], 10, 64); err == nil {
+			words, res.RowsAffected = words[:len(words)-1], n
+		}
+	}
+	// INSERT's count follows an OID, which is always 0.
+	if len(words) == 2 && words[0] == "INSERT" {
+		words = words[:1]
+	}
+	res.Command = strings.Join(words, " ")
+	return res
+}
+
+// BatchError reports the statement that stopped ExecBatch.
+type BatchError struct {
+	Index     int // position of the statement in the batch
+	Statement string
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("statement %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecBatch runs each statement with Exec, in order, stopping at the first
+// that fails. It returns the results of the statements that succeeded and,
+// if one failed, a *BatchError. The statements are not run in a
+// transaction; use Begin for that.
+func (p *PGLite) ExecBatch(statements []string) ([]CommandResult, error) {
+	results := make([]CommandResult, 0, len(statements))
+	for i, stmt := range statements {
+		res, err := p.exec(p.ctx, stmt)
+		if err != nil {
+			return results, &BatchError{Index: i, Statement: stmt, Err: err}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// commandName returns the command a statement runs, named as in its
+// command tag, given the statement's words.
+func commandName(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	switch words[0] {
+	case "CREATE", "DROP", "ALTER":
+	default:
+		return words[0]
+	}
+
+	// Skip modifiers to find the kind of object, as in
+	// CREATE OR REPLACE FUNCTION or CREATE UNIQUE INDEX.
+	for _, w := range words[1:] {
+		switch w {
+		case "OR", "REPLACE", "UNIQUE", "TEMP", "TEMPORARY", "UNLOGGED", "GLOBAL", "LOCAL":
+			continue
+		}
+		return words[0] + " " + w
+	}
+	return words[0]
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCommandName(t *testing.T) {
+	for sql, want := range map[string]string{
+		"insert into t values (1)":                         "INSERT",
+		"-- comment\nUPDATE t SET a = 1":                   "UPDATE",
+		"CREATE TABLE t (a int)":                           "CREATE TABLE",
+		"create or replace function f() returns int as $$": "CREATE FUNCTION",
+		"CREATE UNIQUE INDEX i ON t (a)":                   "CREATE INDEX",
+		"":                                                 "",
+	} {
+		if got := commandName(sqlWords(sql)); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	res, err := testPG.Exec("CREATE TABLE exec_test (id integer, done boolean);")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if res.Command != "CREATE TABLE" || res.RowsAffected != 0 {
+		t.Errorf("create: got %+v", res)
+	}
+
+	res, err = testPG.Exec("INSERT INTO exec_test SELECT g, false FROM generate_series(1, 5) g;")
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if res.Command != "INSERT" || res.RowsAffected != 5 {
+		t.Errorf("insert: got %+v", res)
+	}
+
+	res, err = testPG.Exec("UPDATE exec_test SET done = true WHERE id <= 3 RETURNING id;")
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if res.Command != "UPDATE" || res.RowsAffected != 3 {
+		t.Errorf("update: got %+v", res)
+	}
+
+	// DML under WITH is counted as the command it runs.
+	res, err = testPG.Exec("WITH gone AS (SELECT 4 AS id) DELETE FROM exec_test USING gone WHERE exec_test.id >= gone.id;")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if res.Command != "DELETE" || res.RowsAffected != 2 {
+		t.Errorf("delete: got %+v", res)
+	}
+}
+
+func TestParseCommandTag(t *testing.T) {
+	for tag, want := range map[string]CommandResult{
+		"INSERT 0 5":   {"INSERT", 5},
+		"UPDATE 3":     {"UPDATE", 3},
+		"SELECT 0":     {"SELECT", 0},
+		"CREATE TABLE": {"CREATE TABLE", 0},
+		"COPY 12":      {"COPY", 12},
+	} {
+		if got := parseCommandTag(tag); got != want {
+			t.Errorf("parseCommandTag(%q) = %+v, want %+v", tag, got, want)
+		}
+	}
+}
+
+func TestExecBatch(t *testing.T) {
+	results, err := testPG.ExecBatch([]string{
+		"CREATE TABLE batch_test (id integer PRIMARY KEY);",
+		"INSERT INTO batch_test VALUES (1), (2);",
+		"INSERT INTO batch_test VALUES (2);",
+		"INSERT INTO batch_test VALUES (3);",
+	})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchError, got: %v", err)
+	}
+	if batchErr.Index != 2 || batchErr.Statement != "INSERT INTO batch_test VALUES (2);" {
+		t.Errorf("unexpected failing statement: %d %q", batchErr.Index, batchErr.Statement)
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "23505" {
+		t.Errorf("expected a unique violation, got: %v", err)
+	}
+	want := []CommandResult{{"CREATE TABLE", 0}, {"INSERT", 2}}
+	if !slices.Equal(results, want) {
+		t.Errorf("results: got %+v, want %+v", results, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Plan is a node of a query plan, as reported by EXPLAIN (FORMAT JSON).
+// Costs are in the planner's arbitrary units and times in milliseconds.
+type Plan struct {
+	NodeType     string  `json:"Node Type"`              // such as "Seq Scan" or "Hash Join"
+	Relationship string  `json:"Parent Relationship"`    // such as "Outer" or "Inner"; "" for the root
+	RelationName string  `json:"Relation Name"`          // the table scanned, for scan nodes
+	Alias        string  `json:"Alias"`                  // the table's alias in the query
+	IndexName    string  `json:"Index Name"`             // the index scanned, for index scans
+	JoinType     string  `json:"Join Type"`              // such as "Inner" or "Left", for joins
+	Filter       string  `json:"Filter"`                 // the condition rows are filtered by
+	StartupCost  float64 `json:"Startup Cost"`           // estimated cost before the first row
+	TotalCost    float64 `json:"Total Cost"`             // estimated cost of all rows
+	PlanRows     float64 `json:"Plan Rows"`              // estimated number of rows
+	PlanWidth    int     `json:"Plan Width"`             // estimated average row width in bytes
+	Plans        []*Plan `json:"Plans"`                  // child nodes
+	StartupTime  float64 `json:"Actual Startup Time"`    // with analyze: time to the first row
+	TotalTime    float64 `json:"Actual Total Time"`      // with analyze: time to the last row
+	ActualRows   float64 `json:"Actual Rows"`            // with analyze: rows returned per loop
+	Loops        float64 `json:"Actual Loops"`           // with analyze: times the node ran
+	RowsRemoved  float64 `json:"Rows Removed by Filter"` // with analyze: rows Filter rejected
+
+	// PlanningTime and ExecutionTime are set on the root node only, with
+	// analyze.
+	PlanningTime  float64 `json:"-"`
+	ExecutionTime float64 `json:"-"`
+
+	// Details holds every field EXPLAIN reported for the node, including
+	// those above other than Plans, by the name EXPLAIN gives it.
+	Details map[string]any `json:"-"`
+}
+
+func (pl *Plan) UnmarshalJSON(b []byte) error {
+	type plan Plan // without the UnmarshalJSON method
+	if err := json.Unmarshal(b, (*plan)(pl)); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &pl.Details); err != nil {
+		return err
+	}
+	delete(pl.Details, "Plans")
+	return nil
+}
+
+// Explain returns the plan PostgreSQL chooses for sql, a single statement.
+// With analyze, it runs EXPLAIN ANALYZE, which executes the statement to
+// report actual row counts and times as well, so that a data-modifying
+// statement takes effect; run it inside a transaction that is rolled back
+// to avoid that.
+func (p *PGLite) Explain(sql string, analyze bool) (*Plan, error) {
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	opts := "FORMAT JSON"
+	if analyze {
+		opts += ", ANALYZE"
+	}
+	res, err := p.QueryRows("EXPLAIN (" + opts + ") " + sql + ";")
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, errors.New("explain: no plan")
+	}
+	return parsePlan(res.Rows[0][0])
+}
+
+// parsePlan decodes the output of EXPLAIN (FORMAT JSON), a one-element
+// array holding the root plan node and the statement's timings.
+func parsePlan(s string) (*Plan, error) {
+	var out []struct {
+		Plan          *Plan   `json:"Plan"`
+		PlanningTime  float64 `json:"Planning Time"`
+		ExecutionTime float64 `json:"Execution Time"`
+	}
+	if err := json.Unmarshal([]byte(s), &out); err != nil {
+		return nil, fmt.Errorf("explain: %w", err)
+	}
+	if len(out) != 1 || out[0].Plan == nil {
+		return nil, errors.New("explain: no plan")
+	}
+	root := out[0].Plan
+	root.PlanningTime, root.ExecutionTime = out[0].PlanningTime, out[0].ExecutionTime
+	return root, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.RunQueries(`CREATE TABLE a (id integer, n integer); CREATE TABLE b (id integer);
+INSERT INTO a SELECT i, i % 10 FROM generate_series(1, 100) i; INSERT INTO b SELECT generate_series(1, 50);`); err != nil {
+		t.Fatal(err)
+	}
+	const q = "SELECT * FROM a JOIN b USING (id) WHERE a.n < 5;"
+
+	plan, err := pg.Explain(q, false)
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if len(plan.Plans) == 0 || plan.TotalCost <= 0 || plan.PlanRows <= 0 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if plan.StartupTime != 0 || plan.ExecutionTime != 0 {
+		t.Errorf("timings without analyze: %+v", plan)
+	}
+	var scans []string
+	var walk func(*Plan)
+	walk = func(pl *Plan) {
+		if pl.NodeType == "Seq Scan" {
+			scans = append(scans, pl.RelationName)
+		}
+		for _, c := range pl.Plans {
+			walk(c)
+		}
+	}
+	walk(plan)
+	if len(scans) != 2 {
+		t.Errorf("expected scans of a and b, got %q", scans)
+	}
+
+	plan, err = pg.Explain(q, true)
+	if err != nil {
+		t.Fatalf("Explain analyze: %v", err)
+	}
+	if plan.ActualRows != 25 || plan.Loops != 1 || plan.ExecutionTime <= 0 {
+		t.Errorf("unexpected analyzed plan: %+v", plan)
+	}
+	if plan.Details["Node Type"] != plan.NodeType {
+		t.Errorf("details: %v", plan.Details)
+	}
+
+	if _, err := pg.Explain("SELECT * FROM missing;", false); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
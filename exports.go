@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ListExports returns the names of the functions the module exports, in
+// sorted order. Besides the pg_initdb, use_socketfile and interactive_one
+// entry points the instance drives itself, the module exports others, such
+// as interactive_read, interactive_write and setup, for CallExport.
+func (p *PGLite) ListExports() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defs := p.mod.ExportedFunctionDefinitions()
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CallExport calls the exported function name with params and returns its
+// results, as an escape hatch for functions the rest of the API does not
+// wrap. Parameters and results are raw WASM values: i32 and i64 values as
+// their bits, pointers as offsets into the module's linear memory.
+//
+// The call runs inside the backend with no checks. A function that writes
+// to memory the backend is using, leaves a transaction or the socket files
+// half done, or is called with the wrong arguments can corrupt the session
+// or crash the module, and later queries may then fail or return wrong
+// results. If the call traps or the module exits, it is recorded as a
+// crash, as for a query: see LastExitCode and Close.
+func (p *PGLite) CallExport(name string, params ...uint64) ([]uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.resume(); err != nil {
+		return nil, err
+	}
+	if p.closed || p.mod.IsClosed() {
+		return nil, ErrClosed
+	}
+	fn := p.mod.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("call export: no exported function %q", name)
+	}
+	if n := len(fn.Definition().ParamTypes()); len(params) != n {
+		return nil, fmt.Errorf("call export %s: got %d parameters, want %d", name, len(params), n)
+	}
+	results, err := fn.Call(p.ctx, params...)
+	if err != nil {
+		p.callFailed(err)
+		return nil, fmt.Errorf("call export %s: %w", name, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListExports(t *testing.T) {
+	names := testPG.ListExports()
+	for _, want := range []string{"interactive_one", "pg_initdb", "use_socketfile"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("%s missing from %v", want, names)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("exports not sorted: %v", names)
+	}
+}
+
+func TestCallExport(t *testing.T) {
+	if _, err := testPG.CallExport("no_such_function"); err == nil {
+		t.Error("expected an error for a missing export")
+	}
+	if _, err := testPG.CallExport("interactive_one", 1, 2); err == nil {
+		t.Error("expected an error for the wrong number of parameters")
+	}
+
+	// interactive_read only inspects the input, leaving the session usable.
+	if _, err := testPG.CallExport("interactive_read"); err != nil {
+		t.Fatalf("CallExport: %v", err)
+	}
+	if err := testPG.Ping(testPG.ctx); err != nil {
+		t.Errorf("Ping after CallExport: %v", err)
+	}
+}
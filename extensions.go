@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ExtensionError reports requested extensions that the WASM build does not
+// include.
+type ExtensionError struct {
+	Missing   []string
+	Available []string
+}
+
+func (e *ExtensionError) Error() string {
+	return fmt.Sprintf("extensions not available in this build: %s (available: %s)",
+		strings.Join(e.Missing, ", "), strings.Join(e.Available, ", "))
+}
+
+// createExtensions creates the named extensions, after checking that all
+// of them are available.
+func (p *PGLite) createExtensions(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	res, err := p.QueryRows("SELECT name FROM pg_available_extensions ORDER BY name;")
+	if err != nil {
+		return fmt.Errorf("list extensions: %w", err)
+	}
+	available := make([]string, len(res.Rows))
+	for i, row := range res.Rows {
+		available[i] = row[0]
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !slices.Contains(available, name) {
+			missing = append(missing, name)
+		}
+	}
+	if missing != nil {
+		return &ExtensionError{Missing: missing, Available: available}
+	}
+
+	for _, name := range names {
+		q := "CREATE EXTENSION IF NOT EXISTS " + quoteIdent(name) + ";"
+		if err := p.Query(q); err != nil {
+			return fmt.Errorf("create extension %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// quoteIdent quotes s as an identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	dir := t.TempDir()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:    dir,
+		Extensions: []string{"plpgsql"},
+	})
+	if err != nil {
+		t.Fatalf("plpgsql: %v", err)
+	}
+	pg.Close()
+
+	_, err = NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:    dir,
+		Extensions: []string{"plpgsql", "vector"},
+	})
+	var extErr *ExtensionError
+	if !errors.As(err, &extErr) {
+		t.Fatalf("expected ExtensionError, got: %v", err)
+	}
+	if !slices.Equal(extErr.Missing, []string{"vector"}) {
+		t.Errorf("unexpected missing extensions: %q", extErr.Missing)
+	}
+	if !slices.Contains(extErr.Available, "plpgsql") {
+		t.Errorf("expected plpgsql to be listed as available: %q", extErr.Available)
+	}
+}
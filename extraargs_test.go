@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtraArgs(t *testing.T) {
+	dir := t.TempDir()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:   dir,
+		ExtraArgs: []string{"-c", "work_mem=12MB", "--statement-timeout=5s"},
+	})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	// The settings survive the restart after an error.
+	for i := 0; i < 2; i++ {
+		res, err := pg.QueryRows("SELECT current_setting('work_mem'), current_setting('statement_timeout');")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(res.Rows[0], " "); got != "12MB 5s" {
+			t.Errorf("attempt %d: got %s", i, got)
+		}
+		pg.Query("SELECT * FROM missing;")
+	}
+
+	for _, args := range [][]string{
+		{"--single"},
+		{"-B", "2000"},
+		{"-c", "no_equals"},
+		{"-c", "shared_buffers=64MB"},
+	} {
+		_, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), ExtraArgs: args})
+		if err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestParseExtraArgs(t *testing.T) {
+	got, err := parseExtraArgs([]string{"-c", "a=1", "-cb=2", "--log-min-messages=warning", "-c", "empty="})
+	want := [][2]string{{"a", "1"}, {"b", "2"}, {"log_min_messages", "warning"}, {"empty", ""}}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+	if _, err := parseExtraArgs([]string{"-c"}); err == nil {
+		t.Error("expected an error for -c without a setting")
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// manifest holds the SHA-256 of every regular file in the embedded
+// tarball, one "<hex>  <path>" line per file as sha256sum prints them.
+// TestManifest checks it against the tarball and rewrites it when run with
+// -update-manifest.
+//
+//go:embed pglite-wasi.sha256
+var manifest string
+
+// extractedMarker is written to the data directory once the installation
+// tree has been extracted and verified in full.
+const extractedMarker = ".pglite-extracted"
+
+// clusterDir is where the tarball puts the cluster, relative to the data
+// directory.
+const clusterDir = "tmp/pglite/base"
+
+// tarballName is the name of the installation tarball, in the package and
+// in Options.TarballFS.
+const tarballName = "pglite-wasi.tar.gz"
+
+// openTarball opens the installation tarball in src, or the embedded one
+// if src is nil.
+func openTarball(src fs.FS) (io.ReadCloser, error) {
+	if src != nil {
+		f, err := src.Open(tarballName)
+		if err != nil {
+			return nil, fmt.Errorf("tarball: %w", err)
+		}
+		return f, nil
+	}
+	if len(compressed) == 0 {
+		return nil, errors.New("tarball: none embedded, as built with the pglite_noembed tag; set Options.TarballFS")
+	}
+	return io.NopCloser(bytes.NewReader(compressed)), nil
+}
+
+// parseManifest returns the checksums in a manifest by path.
+func parseManifest(s string) (map[string]string, error) {
+	sums := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		sum, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok || len(sum) != 2*sha256.Size {
+			return nil, fmt.Errorf("manifest: malformed line %q", sc.Text())
+		}
+		sums[name] = sum
+	}
+	return sums, sc.Err()
+}
+
+// installEnv extracts the tarball in src, or the embedded one if src is
+// nil, into dataDir, skipping what is already in place, and then marks the
+// directory as extracted.
+//
+// Unless the directory is already marked, each installation file present
+// is checked against the manifest and replaced if it differs, so the tree
+// left by an interrupted extraction is repaired rather than trusted. Files
+// are written under a temporary name and renamed into place, so none is
+// ever left half-written.
+//
+// The cluster is different: once the instance has run, its files no
+// longer match the tarball. A cluster that is missing is extracted into a
+// staging directory and renamed into place complete. One that is present
+// is kept, and only files missing from it are restored, which repairs a
+// cluster cut short by an older, non-staging extraction.
+//
+// If ctx is done, installEnv stops between files or partway through one
+// and returns ctx.Err(). It removes the staging directory and the file it
+// was writing; the files already in place are whole, and the next
+// extraction keeps them.
+func installEnv(ctx context.Context, dataDir string, src fs.FS) (err error) {
+	sums, err := parseManifest(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(filepath.Join(dataDir, extractedMarker))
+	verify := err != nil
+
+	cluster := filepath.Join(dataDir, clusterDir)
+	staging := filepath.Join(dataDir, "tmp/pglite/.base-extract")
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	_, err = os.Stat(cluster)
+	stage := errors.Is(err, os.ErrNotExist)
+	defer func() {
+		if err != nil && stage {
+			os.RemoveAll(staging)
+		}
+	}()
+
+	tarball, err := openTarball(src)
+	if err != nil {
+		return err
+	}
+	defer tarball.Close()
+	gr, err := gzip.NewReader(ctxReader{ctx, tarball})
+	if err != nil {
+		return fmt.Errorf("tarball: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("tar entry %q is outside the data directory", header.Name)
+		}
+		dest := filepath.Join(dataDir, filepath.FromSlash(name))
+		inCluster := name == clusterDir || strings.HasPrefix(name, clusterDir+"/")
+		if inCluster && stage {
+			dest = filepath.Join(staging, filepath.FromSlash(strings.TrimPrefix(name, clusterDir)))
+		}
+		parent := filepath.Dir(dest)
+		if header.Typeflag == tar.TypeDir {
+			parent = dest
+		}
+		if err := checkNoSymlinks(dataDir, parent); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			sum, ok := sums[name]
+			if !ok {
+				return fmt.Errorf("manifest: no checksum for %s", name)
+			}
+			if !stage || !inCluster {
+				switch ok, err := fileMatches(dest, sum, verify && !inCluster); {
+				case err != nil:
+					return err
+				case ok:
+					continue
+				}
+			}
+			if err := writeFile(dest, tr, sum, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := path.Join(path.Dir(name), header.Linkname)
+			if path.IsAbs(header.Linkname) || !filepath.IsLocal(filepath.FromSlash(target)) {
+				return fmt.Errorf("tar entry %q links outside the data directory, to %s", header.Name, header.Linkname)
+			}
+			if link, err := os.Readlink(dest); err == nil && link == header.Linkname {
+				continue
+			}
+			if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, dest); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown file type in tar: %c (%s)", header.Typeflag, header.Name)
+		}
+	}
+
+	if stage {
+		if err := os.Rename(staging, cluster); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dataDir, extractedMarker), []byte(BundledBuild+"\n"), 0o644)
+}
+
+// checkNoSymlinks reports an error if dir, or any directory between root
+// and it, is a symbolic link, so that extraction never writes through one
+// to a place outside root. Directories that do not exist yet are fine.
+func checkNoSymlinks(root, dir string) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return err
+	}
+	p := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symbolic link, which extraction does not follow", p)
+		}
+	}
+	return nil
+}
+
+// fileMatches reports whether the file at path is in place: whether it
+// exists, and if check is set, whether its contents have the checksum sum.
+func fileMatches(path, sum string, check bool) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if !check {
+		return true, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == sum, nil
+}
+
+// ctxReader reads from r until ctx is done, and then fails with ctx.Err().
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(b []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(b)
+}
+
+// writeFile writes the contents read from r to path, by way of a
+// temporary file renamed into place, and checks them against sum.
+func writeFile(path string, r io.Reader, sum string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%s: checksum %s does not match manifest %s", path, got, sum)
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// checkDir reports, as an error that says what to do about it, a path that
+// exists but is not a directory, including a symbolic link to nothing. A
+// missing path is fine.
+func checkDir(path string) error {
+	if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is a symbolic link to a missing target; remove it or point it at a directory", path)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s exists and is not a directory; move it aside or use another data directory", path)
+	}
+	return nil
+}
+
+// checkDataDir checks that dataDir holds what the module needs to start:
+// the directories it mounts and writes to and the installation tree. It
+// turns what would otherwise fail obscurely inside the module into an
+// error saying what is wrong. A cluster of another PostgreSQL version is
+// left to the backend, whose StartError says so.
+func checkDataDir(dataDir string) error {
+	for _, dir := range []string{"", "tmp", "tmp/pglite", clusterDir} {
+		if err := checkDir(filepath.Join(dataDir, dir)); err != nil {
+			return err
+		}
+	}
+	share := filepath.Join(dataDir, "tmp/pglite/share/postgresql")
+	if info, err := os.Stat(share); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s lacks the installation tree (no directory %s); extract it with ExtractEnv", dataDir, share)
+	}
+	return nil
+}
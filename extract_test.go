@@ -0,0 +1,310 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var updateManifest = flag.Bool("update-manifest", false, "rewrite pglite-wasi.sha256 from the embedded tarball")
+
+func TestManifest(t *testing.T) {
+	gr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	want := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			t.Fatal(err)
+		}
+		want[header.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	if *updateManifest {
+		var b strings.Builder
+		for _, name := range slices.Sorted(maps.Keys(want)) {
+			fmt.Fprintf(&b, "%s  %s\n", want[name], name)
+		}
+		if err := os.WriteFile("pglite-wasi.sha256", []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	got, err := parseManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("pglite-wasi.sha256 does not match the tarball; run go test -run TestManifest -update-manifest")
+	}
+}
+
+func TestExtractEnvRepairs(t *testing.T) {
+	dir := t.TempDir()
+	if err := extractEnv(context.Background(), dir, nil, nopLogger{}); err != nil {
+		t.Fatalf("extractEnv: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, extractedMarker)); err != nil {
+		t.Fatalf("no marker after extraction: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+	sample := path("tmp/pglite/share/postgresql/postgresql.conf.sample")
+	original, err := os.ReadFile(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave the tree as an interrupted extraction might: no marker, a
+	// truncated and a missing installation file, and a missing cluster
+	// file. Change a cluster file as a running instance would.
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(os.Remove(path(extractedMarker)))
+	must(os.WriteFile(sample, original[:10], 0o644))
+	must(os.Remove(path("tmp/pglite/share/postgresql/pg_ident.conf.sample")))
+	must(os.Remove(path("tmp/pglite/share/postgresql/timezone/Japan")))
+	must(os.Remove(path(clusterDir + "/pg_ident.conf")))
+	conf := path(clusterDir + "/postgresql.conf")
+	must(os.WriteFile(conf, []byte("# changed\n"), 0o600))
+
+	var log strings.Builder
+	if err := extractEnv(context.Background(), dir, nil, NewLogger(&log)); err != nil {
+		t.Fatalf("extractEnv: %v", err)
+	}
+	if !strings.Contains(log.String(), "extracting env") {
+		t.Error("expected an unmarked directory to be extracted again")
+	}
+	if got, _ := os.ReadFile(sample); !bytes.Equal(got, original) {
+		t.Error("truncated installation file was not replaced")
+	}
+	for _, name := range []string{
+		"tmp/pglite/share/postgresql/pg_ident.conf.sample",
+		"tmp/pglite/share/postgresql/timezone/Japan",
+		clusterDir + "/pg_ident.conf",
+		extractedMarker,
+	} {
+		if _, err := os.Stat(path(name)); err != nil {
+			t.Errorf("%s not restored: %v", name, err)
+		}
+	}
+	if got, _ := os.ReadFile(conf); string(got) != "# changed\n" {
+		t.Errorf("cluster file was overwritten: %q", got)
+	}
+
+	// A marked directory is left alone.
+	log.Reset()
+	if err := extractEnv(context.Background(), dir, nil, NewLogger(&log)); err != nil || log.Len() != 0 {
+		t.Errorf("second extractEnv: %v, log %q", err, log.String())
+	}
+}
+
+func TestExtractEnvStagesCluster(t *testing.T) {
+	dir := t.TempDir()
+	if err := extractEnv(context.Background(), dir, nil, nopLogger{}); err != nil {
+		t.Fatalf("extractEnv: %v", err)
+	}
+
+	// A cluster removed by ResetOnStart, with the debris of an earlier
+	// staging that was cut short.
+	staging := filepath.Join(dir, "tmp/pglite/.base-extract")
+	if err := os.RemoveAll(filepath.Join(dir, clusterDir)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(staging, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractEnv(context.Background(), dir, nil, nopLogger{}); err != nil {
+		t.Fatalf("extractEnv: %v", err)
+	}
+	for _, name := range []string{"PG_VERSION", "postgresql.conf", "global/pg_control"} {
+		if _, err := os.Stat(filepath.Join(dir, clusterDir, name)); err != nil {
+			t.Errorf("cluster file %s missing: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Errorf("staging directory left behind: %v", err)
+	}
+}
+
+func TestExtractEnvCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Cancel once the cluster is being staged, partway through.
+		for {
+			if _, err := os.Stat(filepath.Join(dir, "tmp/pglite/.base-extract")); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := extractEnv(ctx, dir, nil, nopLogger{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	for _, name := range []string{extractedMarker, clusterDir, "tmp/pglite/.base-extract"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", name, err)
+		}
+	}
+
+	if err := extractEnv(context.Background(), dir, nil, nopLogger{}); err != nil {
+		t.Fatalf("extractEnv after cancellation: %v", err)
+	}
+	if !extracted(dir) {
+		t.Error("extraction incomplete")
+	}
+
+	_, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("NewPGLiteWithOptions with a cancelled context: %v", err)
+	}
+}
+
+func TestExtractEnvNotADirectory(t *testing.T) {
+	file := t.TempDir()
+	if err := os.WriteFile(filepath.Join(file, "tmp"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := t.TempDir()
+	if err := os.Symlink(filepath.Join(link, "gone"), filepath.Join(link, "tmp")); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, want := range map[string]string{
+		file: "tmp exists and is not a directory",
+		link: "tmp is a symbolic link to a missing target",
+	} {
+		_, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want an error saying %q", err, want)
+		}
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkDataDir(dir); err == nil || !strings.Contains(err.Error(), "lacks the installation tree") {
+		t.Errorf("empty directory: got %v", err)
+	}
+	if err := extractEnv(context.Background(), dir, nil, nopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDataDir(dir); err != nil {
+		t.Errorf("extracted directory: %v", err)
+	}
+}
+
+func TestTarballFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, tarballName), compressed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard,
+		Options{DataDir: t.TempDir(), TarballFS: os.DirFS(dir)})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer p.Close()
+	if n, err := p.QueryCount("SELECT 1"); err != nil || n != 1 {
+		t.Errorf("SELECT 1 = %d, %v", n, err)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing": {},
+		"corrupt": {tarballName: {Data: []byte("not a tarball")}},
+	} {
+		if err := ExtractEnvFS(t.TempDir(), fsys); err == nil || !strings.Contains(err.Error(), "tarball") {
+			t.Errorf("%s tarball: got %v", name, err)
+		}
+	}
+}
+
+func TestExtractEnvRefusesEscapes(t *testing.T) {
+	archive := func(hdrs ...*tar.Header) fstest.MapFS {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for _, h := range hdrs {
+			if err := tw.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tw.Close()
+		gw.Close()
+		return fstest.MapFS{tarballName: {Data: buf.Bytes()}}
+	}
+
+	for _, h := range []*tar.Header{
+		{Name: "../escape/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "tmp/../../escape/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "tmp/pglite/lib", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		{Name: "tmp/pglite/lib", Typeflag: tar.TypeSymlink, Linkname: "../../../escape"},
+	} {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "data")
+		if err := ExtractEnvFS(dir, archive(h)); err == nil {
+			t.Errorf("%s -> %s: expected an error", h.Name, h.Linkname)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "escape")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: created outside the data directory: %v", h.Name, err)
+		}
+	}
+
+	// A link inside the tree is not followed by the entries after it, nor
+	// is one already in the data directory.
+	outside := t.TempDir()
+	dir := t.TempDir()
+	err := ExtractEnvFS(dir, archive(
+		&tar.Header{Name: "tmp/", Typeflag: tar.TypeDir, Mode: 0o755},
+		&tar.Header{Name: "tmp/share", Typeflag: tar.TypeSymlink, Linkname: "."},
+		&tar.Header{Name: "tmp/share/lib/", Typeflag: tar.TypeDir, Mode: 0o755},
+	))
+	if err == nil || !strings.Contains(err.Error(), "symbolic link") {
+		t.Errorf("entry through a link in the tarball: got %v", err)
+	}
+	dir = t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "tmp")); err != nil {
+		t.Fatal(err)
+	}
+	err = ExtractEnvFS(dir, archive(&tar.Header{Name: "tmp/pglite/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	if err == nil || !strings.Contains(err.Error(), "symbolic link") {
+		t.Errorf("entry through an existing link: got %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("written through the link: %v", entries)
+	}
+}
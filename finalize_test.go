@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"weak"
+)
+
+// created holds every instance the tests create, and leaked the data
+// directories of those collected without being closed, for checkClosed.
+// The instances are held weakly, so that closed ones are still collected.
+var (
+	createdMu sync.Mutex
+	created   []weak.Pointer[PGLite]
+	leaked    []string
+)
+
+func trackInstance(p *PGLite) {
+	createdMu.Lock()
+	defer createdMu.Unlock()
+	created = append(created, weak.Make(p))
+}
+
+func trackLeak(dataDir string) {
+	createdMu.Lock()
+	defer createdMu.Unlock()
+	leaked = append(leaked, dataDir)
+}
+
+// checkClosed returns an error naming the instances the tests left open,
+// apart from those the database/sql driver keeps for the process's life.
+// Those already collected are found only once their finalizers have run.
+func checkClosed() error {
+	runtime.GC()
+	createdMu.Lock()
+	defer createdMu.Unlock()
+	defaultDriver.mu.Lock()
+	defer defaultDriver.mu.Unlock()
+	open := slices.Clone(leaked)
+	for _, w := range created {
+		p := w.Value()
+		if p == nil || slices.Contains(slices.Collect(maps.Values(defaultDriver.instances)), p) {
+			continue
+		}
+		p.mu.Lock()
+		if !p.closed {
+			open = append(open, p.dataDir)
+		}
+		p.mu.Unlock()
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("%d instances not closed, on:\n%s", len(open), strings.Join(open, "\n"))
+	}
+	return nil
+}
+
+func TestFinalizerWarns(t *testing.T) {
+	// No Logger is set, so the warning goes to stderr.
+	var stderr bytes.Buffer
+	dir := t.TempDir()
+	func() {
+		_, err := NewPGLiteWithOptions(context.Background(), io.Discard, &stderr, Options{DataDir: dir})
+		if err != nil {
+			t.Fatalf("NewPGLiteWithOptions: %v", err)
+		}
+	}()
+
+	// The leak is deliberate, so take it off the list checkClosed reports.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		runtime.GC()
+		createdMu.Lock()
+		i := slices.Index(leaked, dir)
+		if i >= 0 {
+			leaked = slices.Delete(leaked, i, i+1)
+		}
+		createdMu.Unlock()
+		if i >= 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unclosed instance not finalized")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(stderr.String(), "pglite: warning: instance on "+dir+" was not closed") {
+		t.Errorf("no warning written to stderr: %q", stderr.String())
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// QueryString runs the statements in sql in turn, split as by RunQueries,
+// and returns the rows of each formatted as psql's aligned tables, with a
+// blank line between them:
+//
+//	 id | name
+//	----+------
+//	  1 | one
+//	  2 |
+//	(2 rows)
+//
+// Statements that return no rows, such as CREATE TABLE or INSERT, add
+// nothing. NULL is shown as an empty value. Only the statements' rows are
+// included, not the messages PostgreSQL logs while running them. If a
+// statement fails, QueryString stops and returns the tables so far and the
+// error.
+func (p *PGLite) QueryString(sql string) (string, error) {
+	var b strings.Builder
+	for _, stmt := range splitStatements(sql) {
+		res, err := p.QueryRows(stmt)
+		if err != nil {
+			return b.String(), err
+		}
+		if len(res.Columns) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		formatTable(&b, res)
+	}
+	return b.String(), nil
+}
+
+// formatTable writes res to b as a psql aligned table.
+func formatTable(b *strings.Builder, res *Result) {
+	widths := make([]int, len(res.Columns))
+	for i, c := range res.Columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for _, row := range res.Rows {
+		for i, v := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(v))
+		}
+	}
+
+	line := func(cells []string, align func(i int, s string) string) {
+		var l strings.Builder
+		for i, s := range cells {
+			if i > 0 {
+				l.WriteString(" |")
+			}
+			l.WriteString(" " + align(i, s))
+		}
+		b.WriteString(strings.TrimRight(l.String(), " ") + "\n")
+	}
+	pad := func(n int) string {
+		return strings.Repeat(" ", n)
+	}
+
+	line(res.Columns, func(i int, s string) string {
+		n := widths[i] - utf8.RuneCountInString(s)
+		return pad(n/2) + s + pad(n-n/2)
+	})
+	dashes := make([]string, len(widths))
+	for i, w := range widths {
+		dashes[i] = strings.Repeat("-", w+2)
+	}
+	b.WriteString(strings.Join(dashes, "+") + "\n")
+	for _, row := range res.Rows {
+		line(row, func(i int, s string) string {
+			n := widths[i] - utf8.RuneCountInString(s)
+			switch res.Types[i] {
+			case int2OID, int4OID, int8OID, float4OID, float8OID, numericOID, oidOID:
+				return pad(n) + s // numbers are right-aligned
+			}
+			return s + pad(n)
+		})
+	}
+	if len(res.Rows) == 1 {
+		b.WriteString("(1 row)\n")
+	} else {
+		fmt.Fprintf(b, "(%d rows)\n", len(res.Rows))
+	}
+}
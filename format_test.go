@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryString(t *testing.T) {
+	got, err := testPG.QueryString(`
+		CREATE TEMP TABLE qs (id int, name text, price numeric);
+		INSERT INTO qs VALUES (1, 'one', 1.5), (22, NULL, 10), (3, 'ünïcode', NULL);
+		SELECT * FROM qs ORDER BY id;
+		SELECT 'x' AS "a longer name";
+		DROP TABLE qs;`)
+	if err != nil {
+		t.Fatalf("QueryString: %v", err)
+	}
+	want := ` id |  name   | price
+----+---------+-------
+  1 | one     |   1.5
+  3 | ünïcode |
+ 22 |         |    10
+(3 rows)
+
+ a longer name
+---------------
+ x
+(1 row)
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = testPG.QueryString("SELECT 1 AS n; SELECT * FROM missing; SELECT 2;")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.HasPrefix(got, " n\n") || strings.Contains(got, "2") {
+		t.Errorf("output before the error: %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// TouchedAt returns when the instance last ran a query, or when it started
+// if it has run none, for a pool to find the instances to evict. The
+// rounds of VacuumInterval and the suspension of IdleTimeout do not count.
+func (p *PGLite) TouchedAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.touched
+}
+
+// suspendWhenIdle suspends the instance whenever it has run no queries for
+// d, until stop is closed.
+func (p *PGLite) suspendWhenIdle(d time.Duration, stop <-chan struct{}) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		next := d
+		if !p.suspended {
+			if idle := time.Since(p.touched); idle < d {
+				next = d - idle
+			} else if err := p.suspend(); err != nil {
+				p.log.Errorf("%v", err)
+			}
+		}
+		p.mu.Unlock()
+		t.Reset(next)
+	}
+}
+
+// suspend checkpoints the cluster, writes the IdleSnapshot if there is one,
+// and closes the module, to be started again by resume. It does nothing
+// while a transaction is open, which would be lost. The caller must hold
+// p.mu.
+func (p *PGLite) suspend() error {
+	touched := p.touched
+	defer func() { p.touched = touched }()
+
+	open, err := p.transactionOpen()
+	if err != nil {
+		return fmt.Errorf("suspend: %w", err)
+	}
+	if open {
+		return nil
+	}
+	if err := p.query(p.ctx, "CHECKPOINT;", nil, nil); err != nil {
+		return fmt.Errorf("suspend: %w", err)
+	}
+	if p.opts.IdleSnapshot != nil {
+		if err := p.idleSnapshot(); err != nil {
+			p.log.Errorf("suspend: snapshot: %v", err)
+		}
+	}
+	p.log.Debugf("suspending idle instance on %s", p.dataDir)
+	p.suspended = true
+	return p.mod.Close(p.ctx)
+}
+
+// idleSnapshot writes a snapshot to the writer from Options.IdleSnapshot.
+func (p *PGLite) idleSnapshot() error {
+	w, err := p.opts.IdleSnapshot()
+	if err != nil {
+		return err
+	}
+	err = p.writeSnapshot(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// resume starts the module again if it is suspended. The caller must hold
+// p.mu.
+func (p *PGLite) resume() error {
+	if !p.suspended || p.closed {
+		return nil
+	}
+	p.log.Debugf("resuming instance on %s", p.dataDir)
+	if err := p.restart(p.opts); err != nil {
+		return fmt.Errorf("resume: %w", err)
+	}
+	return nil
+}
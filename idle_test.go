@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// nopCloser is a bytes.Buffer with a Close method.
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+// waitSuspended waits for pg to be suspended, or reports whether it was
+// within d.
+func waitSuspended(pg *PGLite, d time.Duration) bool {
+	for deadline := time.Now().Add(d); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		pg.mu.Lock()
+		suspended := pg.suspended
+		pg.mu.Unlock()
+		if suspended {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIdleTimeout(t *testing.T) {
+	var snapshot bytes.Buffer
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:      t.TempDir(),
+		IdleTimeout:  200 * time.Millisecond,
+		IdleSnapshot: func() (io.WriteCloser, error) { return nopCloser{&snapshot}, nil },
+	})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE idle (n int); INSERT INTO idle VALUES (1), (2);"); err != nil {
+		t.Fatal(err)
+	}
+	touched := pg.TouchedAt()
+
+	if !waitSuspended(pg, 5*time.Second) {
+		t.Fatal("instance not suspended")
+	}
+	if got := pg.TouchedAt(); !got.Equal(touched) {
+		t.Errorf("TouchedAt moved from %v to %v on suspension", touched, got)
+	}
+	if snapshot.Len() == 0 {
+		t.Error("no snapshot written on suspension")
+	}
+
+	// The next query resumes the instance.
+	n, err := pg.QueryCount("SELECT * FROM idle")
+	if err != nil || n != 2 {
+		t.Fatalf("count after resuming: %d, %v", n, err)
+	}
+	if !pg.TouchedAt().After(touched) {
+		t.Error("TouchedAt not moved by a query")
+	}
+
+	// An open transaction keeps the instance from being suspended.
+	tx, err := pg.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO idle VALUES (3);"); err != nil {
+		t.Fatal(err)
+	}
+	if waitSuspended(pg, time.Second) {
+		t.Fatal("instance suspended in a transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitSuspended(pg, 5*time.Second) {
+		t.Fatal("instance not suspended after the transaction")
+	}
+	if err := pg.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown of a suspended instance: %v", err)
+	}
+}
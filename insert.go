@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultInsertBatch is the number of rows InsertRows puts in each INSERT
+// when Options.InsertBatchSize is zero.
+const defaultInsertBatch = 100
+
+// InsertRows inserts rows into the named columns of table and returns the
+// number of rows inserted. table is used as written, so it may be
+// schema-qualified; column names are quoted. Each value is quoted as a
+// literal, as QueryParams does.
+//
+// It is the fast path for bulk writes: rows go in multi-row INSERT ...
+// VALUES statements of Options.InsertBatchSize rows each, fewer if the
+// statement would not fit in a single message to the backend, so each
+// call to the module inserts many rows rather than one. A single row too
+// large for a message fails with ErrQueryTooLarge; load such rows with
+// CopyFrom. Unless a transaction is already open, the statements run in
+// one, so either every row is inserted or none.
+func (p *PGLite) InsertRows(table string, cols []string, rows [][]any) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteIdent(c)
+	}
+	prefix := "INSERT INTO " + table + " (" + strings.Join(quoted, ", ") + ") VALUES "
+
+	batch := p.opts.InsertBatchSize
+	if batch <= 0 {
+		batch = defaultInsertBatch
+	}
+	// A query must fit in a Query message with its NUL.
+	limit := maxQuerySize - 1
+
+	var stmts []string
+	var b strings.Builder
+	n := 0
+	for i, row := range rows {
+		if len(row) != len(cols) {
+			return 0, fmt.Errorf("insert rows: row %d has %d values for %d columns", i, len(row), len(cols))
+		}
+		vals := make([]string, len(row))
+		for j, v := range row {
+			lit, err := literal(v)
+			if err != nil {
+				return 0, fmt.Errorf("insert rows: row %d, column %s: %w", i, cols[j], err)
+			}
+			vals[j] = lit
+		}
+		tuple := "(" + strings.Join(vals, ", ") + ")"
+
+		if n > 0 && (n == batch || b.Len()+2+len(tuple)+1 > limit) {
+			stmts = append(stmts, b.String()+";")
+			b.Reset()
+			n = 0
+		}
+		if n == 0 {
+			b.WriteString(prefix)
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(tuple)
+		n++
+	}
+	stmts = append(stmts, b.String()+";")
+
+	open, err := p.inTransaction()
+	if err != nil {
+		return 0, err
+	}
+	var tx *Tx
+	if !open {
+		if tx, err = p.Begin(); err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+	}
+	var total int64
+	for _, stmt := range stmts {
+		res, err := p.Exec(stmt)
+		if err != nil {
+			return 0, fmt.Errorf("insert rows: %w", err)
+		}
+		total += res.RowsAffected
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("insert rows: %w", err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInsertRows(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), InsertBatchSize: 7})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query(`CREATE TABLE ins (id integer PRIMARY KEY, "Label" text, note text);`); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows [][]any
+	for i := 0; i < 50; i++ {
+		rows = append(rows, []any{i, fmt.Sprintf("it's %d", i), nil})
+	}
+	// Long values split the batches by size as well.
+	rows = append(rows, []any{50, strings.Repeat("x", 3000), "a"}, []any{51, strings.Repeat("y", 3000), "b"})
+	n, err := pg.InsertRows("ins", []string{"id", "Label", "note"}, rows)
+	if err != nil || n != 52 {
+		t.Fatalf("InsertRows: %d, %v", n, err)
+	}
+	var label string
+	if err := pg.QueryRow(`SELECT "Label" FROM ins WHERE id = 3 AND note IS NULL;`).Scan(&label); err != nil || label != "it's 3" {
+		t.Errorf("row 3: %q, %v", label, err)
+	}
+
+	// A failing batch leaves none of the rows inserted.
+	_, err = pg.InsertRows("ins", []string{"id"}, [][]any{{100}, {101}, {102}, {103}, {104}, {105}, {106}, {107}, {1}})
+	if err == nil {
+		t.Fatal("expected a duplicate key error")
+	}
+	var count int
+	if err := pg.QueryRow("SELECT count(*) FROM ins;").Scan(&count); err != nil || count != 52 {
+		t.Errorf("count after failed insert: %d, %v", count, err)
+	}
+
+	if _, err := pg.InsertRows("ins", []string{"id", "note"}, [][]any{{1}}); err == nil {
+		t.Error("expected an error for a short row")
+	}
+	if _, err := pg.InsertRows("ins", []string{"id", "note"}, [][]any{{200, strings.Repeat("z", 10000)}}); !errors.Is(err, ErrQueryTooLarge) {
+		t.Errorf("expected ErrQueryTooLarge for an oversized row, got: %v", err)
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: b.TempDir()})
+	if err != nil {
+		b.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE bench (n integer, s text);"); err != nil {
+		b.Fatal(err)
+	}
+	const rowsPerOp = 500
+	rows := make([][]any, rowsPerOp)
+	for i := range rows {
+		rows[i] = []any{i, fmt.Sprintf("row %d", i)}
+	}
+
+	b.Run("InsertRows", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := pg.InsertRows("bench", []string{"n", "s"}, rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("RowByRow", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, row := range rows {
+				if err := pg.QueryParams("INSERT INTO bench (n, s) VALUES ($1, $2);", row...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// QueryJSON runs a query and returns its rows as a JSON array of objects
+// keyed by column name, or [] if there are none. The query is run inside
+// json_agg, so numbers, booleans, nulls and json columns keep their JSON
+// types and everything else becomes a string. sql must be a single query
+// that can be used as a subquery, such as a SELECT or VALUES.
+func (p *PGLite) QueryJSON(sql string) ([]byte, error) {
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	res, err := p.QueryRows("SELECT coalesce(json_agg(pglite_json), '[]') FROM (" + sql + ") pglite_json;")
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, errors.New("query json: no result")
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(res.Rows[0][0])); err != nil {
+		return nil, fmt.Errorf("query json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportJSONL runs a query and writes its rows to w as JSON Lines: one JSON
+// object per row, keyed by column name and typed as for QueryJSON, each on
+// a line of its own. The rows are converted by row_to_json and written as
+// QueryEach reads them, so the result is never held in memory as a whole.
+// query must be a single query that can be used as a subquery; it may end
+// in a semicolon or a comment.
+func (p *PGLite) ExportJSONL(w io.Writer, query string) error {
+	q, err := singleStatement(query)
+	if err != nil {
+		return fmt.Errorf("export jsonl: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	var line bytes.Buffer
+	// A newline ends any trailing comment.
+	err = p.QueryEach("SELECT row_to_json(pglite_jsonl) FROM ("+q+"\n) pglite_jsonl;", func(row []string) error {
+		// json columns keep their own layout, line breaks included.
+		line.Reset()
+		if err := json.Compact(&line, []byte(row[0])); err != nil {
+			return fmt.Errorf("export jsonl: %w", err)
+		}
+		line.WriteByte('\n')
+		_, err := bw.Write(line.Bytes())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestQueryJSON(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT 1 AS n, 'a\"b' AS s, true AS ok, NULL::text AS missing;", `[{"n":1,"s":"a\"b","ok":true,"missing":null}]`},
+		{"VALUES (1.5), (2)", `[{"column1":1.5},{"column1":2}]`},
+		{"SELECT 1 AS n WHERE false;", `[]`},
+	}
+	for _, tt := range tests {
+		got, err := pg.QueryJSON(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	var b strings.Builder
+	err := testPG.ExportJSONL(&b, `SELECT g AS n, 'line
+break' AS s, '{"a":
+  [1, 2]}'::json AS j, NULL::int AS missing FROM generate_series(1, 3) g -- trailing comment`)
+	if err != nil {
+		t.Fatalf("ExportJSONL: %v", err)
+	}
+	want := `{"n":1,"s":"line\nbreak","j":{"a":[1,2]},"missing":null}
+{"n":2,"s":"line\nbreak","j":{"a":[1,2]},"missing":null}
+{"n":3,"s":"line\nbreak","j":{"a":[1,2]},"missing":null}
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := testPG.ExportJSONL(&b, "SELECT 1 WHERE false;"); err != nil || b.Len() != 0 {
+		t.Errorf("no rows: %q, %v", b.String(), err)
+	}
+	if err := testPG.ExportJSONL(&b, "SELECT 1; SELECT 2;"); err == nil {
+		t.Error("expected an error for two statements")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// lockFile does nothing on systems without flock, where extraction is only
+// serialized within the process.
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Logger receives the diagnostics PGLite writes about its own work, such
+// as extracting the installation tree or falling back to the interpreter.
+// PostgreSQL's own output goes to the stdout and stderr writers instead.
+// A Logger may be called from more than one goroutine.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// NewLogger returns a Logger that writes each message to w as a line,
+// prefixed with its level.
+func NewLogger(w io.Writer) Logger {
+	return &writerLogger{w: w}
+}
+
+type writerLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *writerLogger) Debugf(format string, args ...any) { l.logf("debug", format, args...) }
+func (l *writerLogger) Infof(format string, args ...any)  { l.logf("info", format, args...) }
+func (l *writerLogger) Warnf(format string, args ...any)  { l.logf("warning", format, args...) }
+func (l *writerLogger) Errorf(format string, args ...any) { l.logf("error", format, args...) }
+
+func (l *writerLogger) logf(level, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "pglite: %s: %s\n", level, fmt.Sprintf(format, args...))
+}
+
+// quietLogger passes warnings and errors on to its Logger and discards
+// the rest, for when no Logger is set.
+type quietLogger struct{ Logger }
+
+func (quietLogger) Debugf(string, ...any) {}
+func (quietLogger) Infof(string, ...any)  {}
+
+// nopLogger discards everything.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...any) {}
+func (nopLogger) Infof(string, ...any)  {}
+func (nopLogger) Warnf(string, ...any)  {}
+func (nopLogger) Errorf(string, ...any) {}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordLogger records the messages logged to it, by level.
+type recordLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordLogger) add(level, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Debugf(format string, args ...any) { l.add("debug", format, args...) }
+func (l *recordLogger) Infof(format string, args ...any)  { l.add("info", format, args...) }
+func (l *recordLogger) Warnf(format string, args ...any)  { l.add("warning", format, args...) }
+func (l *recordLogger) Errorf(format string, args ...any) { l.add("error", format, args...) }
+
+func TestLogger(t *testing.T) {
+	var log recordLogger
+	var stderr bytes.Buffer
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, &stderr, Options{DataDir: t.TempDir(), Logger: &log})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.RunQueries("SELECT 1;"); err != nil {
+		t.Fatal(err)
+	}
+
+	all := strings.Join(log.msgs, "\n")
+	for _, want := range []string{"info: extracting env", "debug: initdb returned", "debug: REPL: SELECT 1;"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("no %q logged in:\n%s", want, all)
+		}
+	}
+	for _, s := range []string{"extracting env", "initdb returned", "REPL:"} {
+		if strings.Contains(stderr.String(), s) {
+			t.Errorf("%q written to stderr with a Logger set", s)
+		}
+	}
+}
+
+func TestVerbose(t *testing.T) {
+	dir := t.TempDir()
+	for _, verbose := range []bool{false, true} {
+		var stderr bytes.Buffer
+		pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, &stderr, Options{DataDir: dir, Verbose: verbose})
+		if err != nil {
+			t.Fatalf("NewPGLiteWithOptions: %v", err)
+		}
+		pg.Close()
+		if got := strings.Contains(stderr.String(), "initdb returned"); got != verbose {
+			t.Errorf("verbose %v: initdb diagnostic written: %v", verbose, got)
+		}
+	}
+
+	// Without Verbose, warnings and errors still reach stderr.
+	var stderr bytes.Buffer
+	log := Options{}.logger(&stderr)
+	log.Infof("quiet")
+	log.Warnf("loud")
+	if got := stderr.String(); got != "pglite: warning: loud\n" {
+		t.Errorf("default logger wrote %q", got)
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"flag"
 	"log"
 	"os"
 )
@@ -34,27 +34,40 @@ SELECT addition(40,2);
 `
 
 func main() {
-	ctx := context.Background()
+	selftest := flag.Bool("selftest", false, "run a few sample queries before reading from stdin")
+	listen := flag.String("listen", "", "also serve PostgreSQL clients on this TCP address, such as 127.0.0.1:5432")
+	flag.Parse()
 
-	pg, err := NewPGLite(ctx, os.Stdout, os.Stderr)
-	if err != nil {
+	if err := run(*selftest, *listen); err != nil {
 		log.Fatal(err)
 	}
-	defer pg.Close()
+}
 
-	if err := pg.RunQueries(defaultTests); err != nil {
-		log.Fatal(err)
+// run starts an instance and feeds it stdin until stdin ends, with Ctrl-D
+// or at the end of piped input, then closes it. If listen is set, clients
+// connecting there share the session meanwhile.
+func run(selftest bool, listen string) (err error) {
+	pg, err := NewPGLite(context.Background(), os.Stdout, os.Stderr)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if cerr := pg.Close(); err == nil {
+			err = cerr
+		}
+	}()
 
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		input, err := reader.ReadString(';')
+	if listen != "" {
+		addr, err := pg.ServeTCP(listen)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-
-		if err := pg.Query(input); err != nil {
-			log.Fatal(err)
+		log.Printf("listening on %s", addr)
+	}
+	if selftest {
+		if err := pg.RunQueries(defaultTests); err != nil {
+			return err
 		}
 	}
+	return pg.REPL(os.Stdin, os.Stderr)
 }
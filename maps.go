@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Type OIDs of the built-in types QueryMaps converts.
+const (
+	boolOID   = 16
+	int8OID   = 20
+	int2OID   = 21
+	int4OID   = 23
+	float4OID = 700
+	float8OID = 701
+)
+
+// QueryMaps runs a query and returns each row as a map from column name to
+// value. Booleans become bool, integers int64, floating-point numbers
+// float64 and bytea []byte; NULL becomes nil and everything else, numeric
+// included, is kept as the string PostgreSQL printed. Types with a decoder
+// registered by RegisterType become what it returns instead. A column
+// whose name repeats an earlier one gets a suffix, as in "id_2".
+func (p *PGLite) QueryMaps(sql string) ([]map[string]any, error) {
+	res, err := p.QueryRows(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := mapKeys(res.Columns)
+	decoders := p.typeDecoders()
+	maps := make([]map[string]any, len(res.Rows))
+	for i, row := range res.Rows {
+		m := make(map[string]any, len(row))
+		for j, v := range row {
+			switch decode := decoders[res.Types[j]]; {
+			case res.IsNull(i, j):
+				m[keys[j]] = nil
+			case decode != nil:
+				dv, err := decode(v)
+				if err != nil {
+					return nil, fmt.Errorf("query maps: row %d, column %q: %w", i, res.Columns[j], err)
+				}
+				m[keys[j]] = dv
+			default:
+				m[keys[j]] = typedValue(res.Types[j], v)
+			}
+		}
+		maps[i] = m
+	}
+	return maps, nil
+}
+
+// mapKeys returns unique keys for columns, suffixing repeated names.
+func mapKeys(columns []string) []string {
+	keys := make([]string, len(columns))
+	used := make(map[string]bool, len(columns))
+	for i, c := range columns {
+		key := c
+		for n := 2; used[key]; n++ {
+			key = c + "_" + strconv.Itoa(n)
+		}
+		used[key] = true
+		keys[i] = key
+	}
+	return keys
+}
+
+// typedValue converts v, printed by a column of the given type, to a Go
+// value, falling back to the string.
+func typedValue(typeID uint32, v string) any {
+	switch typeID {
+	case boolOID:
+		if b, err := parseBool(v); err == nil {
+			return b
+		}
+	case int2OID, int4OID, int8OID:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case float4OID, float8OID:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case byteaOID:
+		if b, err := DecodeBytea(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMapKeys(t *testing.T) {
+	got := mapKeys([]string{"id", "name", "id", "id_2", "id"})
+	want := []string{"id", "name", "id_2", "id_2_2", "id_3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQueryMaps(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	maps, err := pg.QueryMaps("SELECT 1 AS id, 2::bigint AS id, 'x' AS s, true AS b, 1.5::float8 AS f, 2.50::numeric AS n, NULL AS z;")
+	if err != nil {
+		t.Fatalf("QueryMaps: %v", err)
+	}
+	want := []map[string]any{{
+		"id": int64(1), "id_2": int64(2), "s": "x", "b": true, "f": 1.5, "n": "2.50", "z": nil,
+	}}
+	if !reflect.DeepEqual(maps, want) {
+		t.Errorf("got %#v, want %#v", maps, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// memoryDir is where InMemory data directories are created: a tmpfs on
+// Linux.
+const memoryDir = "/dev/shm"
+
+// tempDataDir runs start with opts.DataDir set to a new directory, in
+// memory if opts.InMemory is set or in opts.TempDir if that is, and
+// arranges for the directory to be removed when the instance is closed, or
+// at once if start fails.
+func tempDataDir(opts Options, start func(Options) (*PGLite, error)) (*PGLite, error) {
+	if !opts.InMemory && opts.TempDir == "" {
+		return start(opts)
+	}
+	what := "in-memory"
+	if !opts.InMemory {
+		what = "temp dir"
+	}
+	if opts.InMemory && opts.TempDir != "" {
+		return nil, errors.New("in-memory: TempDir must not be set")
+	}
+	if opts.DataDir != "" {
+		return nil, fmt.Errorf("%s: DataDir must not be set", what)
+	}
+
+	var dir string
+	var err error
+	if opts.InMemory {
+		dir, err = memoryTempDir()
+	} else {
+		dir, err = os.MkdirTemp(opts.TempDir, "pglite-")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+
+	opts.DataDir = dir
+	p, err := start(opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	p.removeDir = dir
+	return p, nil
+}
+
+// memoryTempDir creates a new directory in memoryDir, or in the system
+// temporary directory where there is no memoryDir.
+func memoryTempDir() (string, error) {
+	base := memoryDir
+	if fi, err := os.Stat(base); err != nil || !fi.IsDir() {
+		base = "" // the system temporary directory
+	}
+	return os.MkdirTemp(base, "pglite-")
+}
+
+// NewPGLiteTemp starts an instance on a new data directory in the system
+// temporary directory, which is removed when the instance is closed, or if
+// the start fails, leaving nothing behind. Each call gets a directory of
+// its own, so the instances share nothing, and tests can start one each
+// and run in parallel. PostgreSQL's output is discarded; see SetOutput.
+// Unlike InMemory, the directory is on disk; set Options.TempDir to choose
+// where. NewPGLiteEphemeral is the same constructor under another name.
+func NewPGLiteTemp(ctx context.Context) (*PGLite, error) {
+	return NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{TempDir: os.TempDir()})
+}
+
+// NewPGLiteEphemeral is a wrapper of NewPGLiteTemp, under the name tests
+// tend to look for: the instance runs in a new directory from os.MkdirTemp,
+// which Close removes, as does a failed start. To choose the parent
+// directory, or to set other Options with it, use NewPGLiteWithOptions with
+// Options.TempDir.
+func NewPGLiteEphemeral(ctx context.Context) (*PGLite, error) {
+	return NewPGLiteTemp(ctx)
+}
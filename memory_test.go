@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInMemory(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	dir := pg.dataDir
+	if err := pg.RunQueries("CREATE TABLE mem (n integer); INSERT INTO mem VALUES (1);"); err != nil {
+		t.Fatalf("queries: %v", err)
+	}
+	pg.Close()
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed on Close, got: %v", dir, err)
+	}
+
+	if _, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{InMemory: true, DataDir: t.TempDir()}); err == nil {
+		t.Error("expected an error when DataDir is set too")
+	}
+}
+
+func TestNewPGLiteTemp(t *testing.T) {
+	const n = 4
+	pgs := make([]*PGLite, n)
+	errs := make(chan error, n)
+	for i := range pgs {
+		go func() {
+			pg, err := NewPGLiteTemp(context.Background())
+			if err == nil {
+				pgs[i] = pg
+				err = pg.RunQueries(fmt.Sprintf("CREATE TABLE shard (n integer); INSERT INTO shard VALUES (%d);", i))
+			}
+			errs <- err
+		}()
+	}
+	for range pgs {
+		if err := <-errs; err != nil {
+			t.Fatalf("instance: %v", err)
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for i, pg := range pgs {
+		dirs[pg.dataDir] = true
+		var got int
+		if err := pg.QueryRow("SELECT n FROM shard;").Scan(&got); err != nil || got != i {
+			t.Errorf("instance %d: got %d, %v", i, got, err)
+		}
+		if err := pg.Query("SELECT gen_random_uuid();"); err != nil {
+			t.Errorf("instance %d: random UUID: %v", i, err)
+		}
+		pg.Close()
+		if _, err := os.Stat(pg.dataDir); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed on Close, got: %v", pg.dataDir, err)
+		}
+	}
+	if len(dirs) != n {
+		t.Errorf("%d data directories for %d instances", len(dirs), n)
+	}
+}
+
+func BenchmarkStartup(b *testing.B) {
+	ctx := context.Background()
+	c, err := CompileShared(ctx)
+	if err != nil {
+		b.Fatalf("CompileShared: %v", err)
+	}
+	defer c.Close(ctx)
+
+	b.Run("disk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pg, err := NewFromCompiled(ctx, c, io.Discard, io.Discard, Options{DataDir: b.TempDir()})
+			if err != nil {
+				b.Fatalf("NewFromCompiled: %v", err)
+			}
+			pg.Close()
+		}
+	})
+	b.Run("memory", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pg, err := NewFromCompiled(ctx, c, io.Discard, io.Discard, Options{InMemory: true})
+			if err != nil {
+				b.Fatalf("NewFromCompiled: %v", err)
+			}
+			pg.Close()
+		}
+	})
+}
+
+func TestTempDir(t *testing.T) {
+	parent := t.TempDir()
+	empty := func() bool {
+		t.Helper()
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries) == 0
+	}
+
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{TempDir: parent})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	if filepath.Dir(pg.dataDir) != parent {
+		t.Errorf("data directory %s not in %s", pg.dataDir, parent)
+	}
+	if err := pg.Query("CREATE TABLE temp_dir (n integer);"); err != nil {
+		t.Fatal(err)
+	}
+	pg.Close()
+	if !empty() {
+		t.Error("data directory left behind by Close")
+	}
+
+	// A start that fails partway, once the cluster is extracted.
+	_, err = NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{TempDir: parent, ExtraArgs: []string{"--boot"}})
+	if err == nil {
+		t.Fatal("expected the start to fail")
+	}
+	if !empty() {
+		t.Error("data directory left behind by a failed start")
+	}
+
+	for _, opts := range []Options{{TempDir: parent, DataDir: t.TempDir()}, {TempDir: parent, InMemory: true}} {
+		if _, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestNewPGLiteEphemeral(t *testing.T) {
+	pg, err := NewPGLiteEphemeral(context.Background())
+	if err != nil {
+		t.Fatalf("NewPGLiteEphemeral: %v", err)
+	}
+	if filepath.Dir(pg.dataDir) != filepath.Clean(os.TempDir()) {
+		t.Errorf("data directory %s not in %s", pg.dataDir, os.TempDir())
+	}
+	if err := pg.Query("CREATE TABLE ephemeral (n integer);"); err != nil {
+		t.Fatal(err)
+	}
+	pg.Close()
+	if _, err := os.Stat(pg.dataDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed on Close, got: %v", pg.dataDir, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CheckMigrations runs statements in order inside a transaction that is
+// always rolled back, to check a migration against the engine without
+// changing the database. DDL is checked too, as PostgreSQL runs it
+// transactionally. It stops at the first statement that fails and returns
+// a *BatchError holding its index and the *QueryError PostgreSQL reported.
+//
+// Statements that end or start a transaction, such as COMMIT, would let
+// changes through, so they are refused before anything runs; statements
+// that cannot run in a transaction, such as VACUUM or CREATE DATABASE,
+// fail the check. Other queries wait until the check is done, so they
+// never see its changes. It fails if a transaction is already open.
+func (p *PGLite) CheckMigrations(statements []string) error {
+	for i, stmt := range statements {
+		if cmd, ok := transactionControl(stmt); ok {
+			return &BatchError{Index: i, Statement: stmt, Err: fmt.Errorf("check migrations: %s would end the check's transaction", cmd)}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	open, err := p.transactionOpen()
+	if err != nil {
+		return fmt.Errorf("check migrations: %w", err)
+	}
+	if open {
+		return errors.New("check migrations: a transaction is already in progress")
+	}
+
+	if err := p.query(p.ctx, "BEGIN;", nil, nil); err != nil {
+		return fmt.Errorf("check migrations: %w", err)
+	}
+	for i, stmt := range statements {
+		if err := p.query(p.ctx, stmt, nil, nil); err != nil {
+			// The failure has ended the transaction already, as the
+			// backend restarts after an error; the ROLLBACK makes sure.
+			p.query(p.ctx, "ROLLBACK;", nil, nil)
+			return &BatchError{Index: i, Statement: stmt, Err: err}
+		}
+	}
+	if err := p.query(p.ctx, "ROLLBACK;", nil, nil); err != nil {
+		return fmt.Errorf("check migrations: %w", err)
+	}
+	return nil
+}
+
+// transactionControl returns the command of the first statement in sql
+// that starts or ends a transaction block, and true, if there is one.
+func transactionControl(sql string) (string, bool) {
+	for _, stmt := range splitStatements(sql) {
+		words := sqlWords(stmt)
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "BEGIN", "START", "COMMIT", "END", "ROLLBACK", "ABORT":
+			return words[0], true
+		case "PREPARE":
+			if len(words) > 1 && words[1] == "TRANSACTION" {
+				return "PREPARE TRANSACTION", true
+			}
+		}
+	}
+	return "", false
+}
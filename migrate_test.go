@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckMigrations(t *testing.T) {
+	tableExists := func(name string) bool {
+		t.Helper()
+		var exists bool
+		if err := testPG.QueryRow("SELECT to_regclass('" + name + "') IS NOT NULL;").Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	migration := []string{
+		"CREATE TABLE migrate_test (id integer PRIMARY KEY);",
+		"INSERT INTO migrate_test VALUES (1), (2);",
+		"ALTER TABLE migrate_test ADD COLUMN name text;",
+	}
+	if err := testPG.CheckMigrations(migration); err != nil {
+		t.Fatalf("CheckMigrations: %v", err)
+	}
+	if tableExists("migrate_test") {
+		t.Error("a checked migration changed the database")
+	}
+
+	err := testPG.CheckMigrations(append(migration, "ALTER TABLE migrate_test ADD COLUMN name text;"))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 3 {
+		t.Fatalf("expected a BatchError for statement 3, got: %v", err)
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "42701" {
+		t.Errorf("expected a duplicate column error, got: %v", err)
+	}
+	if tableExists("migrate_test") {
+		t.Error("a failed check changed the database")
+	}
+
+	err = testPG.CheckMigrations([]string{"CREATE TABLE migrate_test (id integer); COMMIT;"})
+	if !errors.As(err, &batchErr) || batchErr.Index != 0 {
+		t.Errorf("expected COMMIT to be refused, got: %v", err)
+	}
+	if tableExists("migrate_test") {
+		t.Error("a refused check changed the database")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// reservedMounts are the guest paths the instance mounts itself.
+var reservedMounts = []string{"/tmp", "/dev"}
+
+// fsConfig returns the sandbox's file system: the data directory's tmp
+// subdirectory at /tmp, the virtual /dev, and the mounts in Mounts and
+// ReadOnlyMounts.
+func (o Options) fsConfig() (wazero.FSConfig, error) {
+	type mount struct {
+		host, guest string
+		readOnly    bool
+	}
+	var mounts []mount
+	for host, guest := range o.Mounts {
+		mounts = append(mounts, mount{host, guest, false})
+	}
+	for host, guest := range o.ReadOnlyMounts {
+		mounts = append(mounts, mount{host, guest, true})
+	}
+	slices.SortFunc(mounts, func(a, b mount) int { return strings.Compare(a.guest, b.guest) })
+
+	cfg := wazero.NewFSConfig().
+		WithDirMount(filepath.Join(o.dataDir(), "tmp"), "/tmp").
+		WithFSMount(devFS{}, "/dev")
+	for i, m := range mounts {
+		if !path.IsAbs(m.guest) || path.Clean(m.guest) != m.guest || m.guest == "/" {
+			return nil, fmt.Errorf("mount %s: guest path %q must be a clean absolute path below /", m.host, m.guest)
+		}
+		for _, r := range reservedMounts {
+			if m.guest == r || strings.HasPrefix(m.guest, r+"/") {
+				return nil, fmt.Errorf("mount %s: guest path %s is within %s, which the instance mounts", m.host, m.guest, r)
+			}
+		}
+		if i > 0 && mounts[i-1].guest == m.guest {
+			return nil, fmt.Errorf("mount %s: guest path %s is also mounted from %s", m.host, m.guest, mounts[i-1].host)
+		}
+		if m.readOnly {
+			cfg = cfg.WithReadOnlyDirMount(m.host, m.guest)
+		} else {
+			cfg = cfg.WithDirMount(m.host, m.guest)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMounts(t *testing.T) {
+	in, out := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(in, "import.csv"), []byte("1,one\n2,two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:        t.TempDir(),
+		ReadOnlyMounts: map[string]string{in: "/data"},
+		Mounts:         map[string]string{out: "/export"},
+	})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.Query("CREATE TABLE imported (n integer, s text);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Query("COPY imported FROM '/data/import.csv' WITH (FORMAT csv);"); err != nil {
+		t.Fatalf("COPY FROM mounted file: %v", err)
+	}
+	var n int
+	if err := pg.QueryRow("SELECT count(*) FROM imported;").Scan(&n); err != nil || n != 2 {
+		t.Errorf("count: got %d, %v", n, err)
+	}
+
+	if err := pg.Query("COPY imported TO '/data/written.csv' WITH (FORMAT csv);"); err == nil {
+		t.Error("expected writing to a read-only mount to fail")
+	}
+	if _, err := os.Stat(filepath.Join(in, "written.csv")); !os.IsNotExist(err) {
+		t.Errorf("read-only mount was written to: %v", err)
+	}
+
+	if err := pg.Query("COPY imported TO '/export/out.csv' WITH (FORMAT csv);"); err != nil {
+		t.Fatalf("COPY TO writable mount: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "out.csv"))
+	if err != nil || string(data) != "1,one\n2,two\n" {
+		t.Errorf("exported file: got %q, %v", data, err)
+	}
+}
+
+func TestMountsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"relative", Options{Mounts: map[string]string{dir: "data"}}, "clean absolute path"},
+		{"root", Options{Mounts: map[string]string{dir: "/"}}, "clean absolute path"},
+		{"unclean", Options{Mounts: map[string]string{dir: "/data/../x"}}, "clean absolute path"},
+		{"tmp", Options{Mounts: map[string]string{dir: "/tmp"}}, "within /tmp"},
+		{"under tmp", Options{ReadOnlyMounts: map[string]string{dir: "/tmp/pglite/x"}}, "within /tmp"},
+		{"dev", Options{Mounts: map[string]string{dir: "/dev/data"}}, "within /dev"},
+		{"duplicate", Options{
+			Mounts:         map[string]string{dir: "/data"},
+			ReadOnlyMounts: map[string]string{t.TempDir(): "/data"},
+		}, "also mounted"},
+	}
+	for _, tt := range tests {
+		_, err := tt.opts.fsConfig()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := (Options{Mounts: map[string]string{dir: "/data"}, ReadOnlyMounts: map[string]string{t.TempDir(): "/tmpdata"}}).fsConfig(); err != nil {
+		t.Errorf("valid mounts: %v", err)
+	}
+}
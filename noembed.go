@@ -0,0 +1,7 @@
+//go:build pglite_noembed
+
+package main
+
+// compressed is empty in programs built with the pglite_noembed tag,
+// which supply the tarball with Options.TarballFS instead.
+var compressed []byte
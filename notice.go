@@ -0,0 +1,13 @@
+package main
+
+// OnNotice registers fn to be called with each NOTICE, WARNING or INFO
+// message a statement reports, such as those from RAISE NOTICE in
+// plpgsql, with its level and text. The messages still appear in the
+// output written to stderr, but never in query results. fn is called with
+// the instance locked, so it must not use the instance. A nil fn removes
+// the callback.
+func (p *PGLite) OnNotice(fn func(level, msg string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onNotice = fn
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestOnNotice(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	var got []string
+	pg.OnNotice(func(level, msg string) { got = append(got, level+": "+msg) })
+
+	if err := pg.Query("DO $$ BEGIN RAISE NOTICE 'step %', 1; RAISE WARNING 'careful'; END $$;"); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	res, err := pg.QueryRows("SELECT 7 AS n;")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "7" {
+		t.Errorf("unexpected rows: %q", res.Rows)
+	}
+
+	want := []string{"NOTICE: step 1", "WARNING: careful"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Notification is a message sent with NOTIFY or pg_notify.
+type Notification struct {
+	Channel string
+	Payload string
+	PID     int // process ID of the notifying backend
+}
+
+// notifyBuffer is the capacity of the channels Listen returns.
+const notifyBuffer = 64
+
+// Listen runs LISTEN on channel and returns a Go channel that receives its
+// notifications, until Unlisten or Close closes it.
+//
+// The instance is its own and only session, so notifications come from
+// statements run on it, through any method or Serve. Each is delivered
+// once the transaction that sent it commits, during the call that ran the
+// committing statement, before that call returns, so there is no polling
+// and no delay. Notifications are not queued for a reader: one that
+// arrives while the Go channel's buffer of 64 is full is dropped, so read
+// the channel from another goroutine if many may arrive at once.
+//
+// Listening survives the restarts that follow a failed statement and
+// UseDatabase, but notifications sent while the backend restarts, by
+// statements that failed, are lost.
+func (p *PGLite) Listen(channel string) (<-chan Notification, error) {
+	if err := p.Query("LISTEN " + quoteIdent(channel) + ";"); err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if p.listening == nil {
+		p.listening = make(map[string][]chan Notification)
+	}
+	ch := make(chan Notification, notifyBuffer)
+	p.listening[channel] = append(p.listening[channel], ch)
+	return ch, nil
+}
+
+// Unlisten runs UNLISTEN on channel and closes the Go channels Listen
+// returned for it.
+func (p *PGLite) Unlisten(channel string) error {
+	if err := p.Query("UNLISTEN " + quoteIdent(channel) + ";"); err != nil {
+		return fmt.Errorf("unlisten: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ch := range p.listening[channel] {
+		close(ch)
+	}
+	delete(p.listening, channel)
+	return nil
+}
+
+// relisten runs LISTEN again for the channels listened on, after a
+// restart. The caller must hold p.mu.
+func (p *PGLite) relisten() error {
+	for channel := range p.listening {
+		if err := p.query(p.ctx, "LISTEN "+quoteIdent(channel)+";", nil, nil); err != nil {
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+// notify delivers a NotificationResponse message to the channel's
+// listeners, if it is well formed. The caller must hold p.mu.
+func (p *PGLite) notify(body []byte) {
+	n, err := parseNotification(body)
+	if err != nil {
+		return
+	}
+	for _, ch := range p.listening[n.Channel] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
+
+// stopListening closes all the Go channels Listen returned. The caller
+// must hold p.mu.
+func (p *PGLite) stopListening() {
+	for _, chs := range p.listening {
+		for _, ch := range chs {
+			close(ch)
+		}
+	}
+	p.listening = nil
+}
+
+// parseNotification decodes the body of a NotificationResponse message:
+// the backend's process ID, then the channel and payload as C strings.
+func parseNotification(body []byte) (Notification, error) {
+	malformed := errors.New("malformed notification")
+	if len(body) < 4 {
+		return Notification{}, malformed
+	}
+	n := Notification{PID: int(int32(binary.BigEndian.Uint32(body)))}
+	fields := bytes.SplitN(body[4:], []byte{0}, 3)
+	if len(fields) != 3 {
+		return Notification{}, malformed
+	}
+	n.Channel, n.Payload = string(fields[0]), string(fields[1])
+	return n, nil
+}
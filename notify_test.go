@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	ch, err := pg.Listen("Events")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case n := <-ch:
+			if n.Channel != "Events" || n.Payload != want {
+				t.Errorf("got %+v, want payload %q", n, want)
+			}
+		default:
+			t.Errorf("no notification, want payload %q", want)
+		}
+	}
+
+	if err := pg.Query(`NOTIFY "Events", 'one';`); err != nil {
+		t.Fatal(err)
+	}
+	expect("one")
+	if err := pg.Query("NOTIFY other, 'ignored';"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notifications sent in a transaction arrive when it commits.
+	if err := pg.RunQueries("BEGIN; SELECT pg_notify('Events', 'two');"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != 0 {
+		t.Error("notification delivered before commit")
+	}
+	if err := pg.Query("COMMIT;"); err != nil {
+		t.Fatal(err)
+	}
+	expect("two")
+
+	// Listening survives the restart after an error.
+	if err := pg.Query("SELECT * FROM missing;"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := pg.Query(`NOTIFY "Events", 'three';`); err != nil {
+		t.Fatal(err)
+	}
+	expect("three")
+
+	if err := pg.Unlisten("Events"); err != nil {
+		t.Fatalf("Unlisten: %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after Unlisten")
+	}
+
+	ch, err = pg.Listen("closing")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	pg.Close()
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after Close")
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	n, err := parseNotification([]byte("\x00\x00\x04\x28chan\x00payload\x00"))
+	if err != nil || n != (Notification{Channel: "chan", Payload: "payload", PID: 1064}) {
+		t.Errorf("got %+v, %v", n, err)
+	}
+	if _, err := parseNotification([]byte("\x00\x00\x04\x28chan")); err == nil {
+		t.Error("expected an error for a truncated notification")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QueryPage runs sql, a single query, for one page of its rows: up to
+// limit rows, after skipping the first offset. hasMore reports whether
+// rows follow the page, found by fetching one row more than limit. sql
+// should have an ORDER BY, or the pages may overlap and miss rows. It
+// must not have a LIMIT, OFFSET or FETCH of its own, outside
+// parentheses, as QueryPage appends them.
+func (p *PGLite) QueryPage(sql string, limit, offset int) (res *Result, hasMore bool, err error) {
+	if limit <= 0 || offset < 0 {
+		return nil, false, fmt.Errorf("query page: invalid limit %d or offset %d", limit, offset)
+	}
+	q, err := singleStatement(sql)
+	if err != nil {
+		return nil, false, fmt.Errorf("query page: %w", err)
+	}
+	words := topLevelWords(q)
+	for i, w := range words {
+		// FETCH is not reserved, so it may name a column.
+		fetch := w == "FETCH" && i+1 < len(words) && (words[i+1] == "FIRST" || words[i+1] == "NEXT")
+		if w == "LIMIT" || w == "OFFSET" || fetch {
+			return nil, false, fmt.Errorf("query page: sql already has %s", w)
+		}
+	}
+
+	// A newline ends any trailing comment.
+	res, err = p.QueryRows(fmt.Sprintf("%s\nLIMIT %d OFFSET %d;", q, limit+1, offset))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(res.Rows) > limit {
+		res.Rows, res.nulls = res.Rows[:limit], res.nulls[:limit]
+		hasMore = true
+	}
+	return res, hasMore, nil
+}
+
+// QueryCount returns the number of rows sql, a single query, returns,
+// counted by the server without sending them, as
+// SELECT count(*) FROM (sql) AS t. sql may end in a semicolon or a comment
+// and have an ORDER BY or LIMIT of its own.
+func (p *PGLite) QueryCount(sql string) (int64, error) {
+	q, err := singleStatement(sql)
+	if err != nil {
+		return 0, fmt.Errorf("query count: %w", err)
+	}
+	// A newline ends any trailing comment.
+	res, err := p.QueryRows("SELECT count(*) FROM (" + q + "\n) AS pglite_count;")
+	if err != nil {
+		return 0, err
+	}
+	if len(res.Rows) != 1 {
+		return 0, errors.New("query count: no count returned")
+	}
+	return strconv.ParseInt(res.Rows[0][0], 10, 64)
+}
+
+// singleStatement returns the one statement in sql, without its semicolon,
+// ignoring comments around it.
+func singleStatement(sql string) (string, error) {
+	var stmt string
+	for _, s := range splitStatements(sql) {
+		if onlyComments(s) {
+			continue
+		}
+		if stmt != "" {
+			return "", errors.New("sql must be a single statement")
+		}
+		stmt = strings.TrimSuffix(s, ";")
+	}
+	if stmt == "" {
+		return "", errors.New("no statement in sql")
+	}
+	return stmt, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryPage(t *testing.T) {
+	const query = "SELECT n FROM generate_series(1, 5) AS n ORDER BY n -- the numbers\n;"
+	tests := []struct {
+		limit, offset int
+		want          string
+		more          bool
+	}{
+		{2, 0, "1,2", true},
+		{2, 2, "3,4", true},
+		{2, 4, "5", false},
+		{5, 0, "1,2,3,4,5", false},
+		{2, 6, "", false},
+	}
+	for _, tt := range tests {
+		res, more, err := testPG.QueryPage(query, tt.limit, tt.offset)
+		if err != nil {
+			t.Errorf("limit %d offset %d: %v", tt.limit, tt.offset, err)
+			continue
+		}
+		var got []string
+		for i, row := range res.Rows {
+			got = append(got, row[0])
+			if res.IsNull(i, 0) {
+				t.Errorf("limit %d offset %d: row %d is NULL", tt.limit, tt.offset, i)
+			}
+		}
+		if strings.Join(got, ",") != tt.want || more != tt.more {
+			t.Errorf("limit %d offset %d: got %v, %v, want %s, %v", tt.limit, tt.offset, got, more, tt.want, tt.more)
+		}
+	}
+
+	// A LIMIT inside parentheses is allowed, as is a column named fetch.
+	res, more, err := testPG.QueryPage("SELECT s.fetch FROM (SELECT n AS fetch FROM generate_series(1, 3) AS n LIMIT 2) AS s ORDER BY 1;", 1, 0)
+	if err != nil || len(res.Rows) != 1 || !more {
+		t.Errorf("subquery with LIMIT: got %v, %v, %v", res, more, err)
+	}
+
+	for _, sql := range []string{
+		"SELECT 1 LIMIT 1;",
+		"SELECT 1 OFFSET 1;",
+		"SELECT 1 FETCH FIRST 1 ROW ONLY;",
+		"SELECT 1; SELECT 2;",
+	} {
+		if _, _, err := testPG.QueryPage(sql, 10, 0); err == nil {
+			t.Errorf("QueryPage(%q): expected an error", sql)
+		}
+	}
+	if _, _, err := testPG.QueryPage("SELECT 1;", 0, 0); err == nil {
+		t.Error("expected an error for a zero limit")
+	}
+}
+
+func TestQueryCount(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want int64
+	}{
+		{"SELECT n FROM generate_series(1, 5) AS n", 5},
+		{"SELECT n FROM generate_series(1, 5) AS n ORDER BY n DESC;", 5},
+		{"SELECT n FROM generate_series(1, 5) AS n LIMIT 2; -- two of them", 2},
+		{"VALUES (1), (2), (3)", 3},
+		{"SELECT 1 WHERE false", 0},
+	}
+	for _, tt := range tests {
+		if got, err := testPG.QueryCount(tt.sql); err != nil || got != tt.want {
+			t.Errorf("QueryCount(%q): got %d, %v, want %d", tt.sql, got, err, tt.want)
+		}
+	}
+	if _, err := testPG.QueryCount("SELECT 1; SELECT 2;"); err == nil {
+		t.Error("expected an error for two statements")
+	}
+	if _, err := testPG.QueryCount("SELECT * FROM missing"); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
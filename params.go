@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryParams executes sql after replacing the placeholders $1, $2, ...
+// with args quoted as SQL literals. Placeholders inside string literals,
+// quoted identifiers and comments are left alone.
+func (p *PGLite) QueryParams(sql string, args ...any) error {
+	q, err := interpolate(sql, args)
+	if err != nil {
+		return err
+	}
+	return p.Query(q)
+}
+
+// QueryRowsParams is like QueryRows but substitutes args as QueryParams
+// does.
+func (p *PGLite) QueryRowsParams(sql string, args ...any) (*Result, error) {
+	q, err := interpolate(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return p.QueryRows(q)
+}
+
+// interpolate replaces the placeholders in sql with args as literals.
+func interpolate(sql string, args []any) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(sql); {
+		if j := skipLiteral(sql, i); j > i {
+			b.WriteString(sql[i:j])
+			i = j
+			continue
+		}
+		if sql[i] != '$' || i+1 == len(sql) || !isDigit(sql[i+1]) || (i > 0 && isIdentChar(sql[i-1])) {
+			b.WriteByte(sql[i])
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(sql) && isDigit(sql[j]) {
+			j++
+		}
+		n, err := strconv.Atoi(sql[i+1 : j])
+		if err != nil || n < 1 || n > len(args) {
+			return "", fmt.Errorf("placeholder %s has no argument (%d given)", sql[i:j], len(args))
+		}
+		lit, err := literal(args[n-1])
+		if err != nil {
+			return "", fmt.Errorf("argument %d: %w", n, err)
+		}
+		b.WriteString(lit)
+		i = j
+	}
+	return b.String(), nil
+}
+
+// literal returns v as a SQL literal.
+func literal(v any) (string, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		v = dv
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteString(v)
+	case []byte:
+		return "'\\x" + hex.EncodeToString(v) + "'::bytea", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int:
+		return intLiteral(int64(v)), nil
+	case int8:
+		return intLiteral(int64(v)), nil
+	case int16:
+		return intLiteral(int64(v)), nil
+	case int32:
+		return intLiteral(int64(v)), nil
+	case int64:
+		return intLiteral(v), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return floatLiteral(float64(v), 32), nil
+	case float64:
+		return floatLiteral(v, 64), nil
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999999Z07:00") + "'::timestamptz", nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+// quoteString quotes s as a string literal. Strings containing backslashes
+// use the E'...' form, so they are read the same way whatever the setting of
+// standard_conforming_strings.
+func quoteString(s string) (string, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return "", errors.New("string contains a NUL byte")
+	}
+	s = strings.ReplaceAll(s, "'", "''")
+	if strings.Contains(s, `\`) {
+		return "E'" + strings.ReplaceAll(s, `\`, `\\`) + "'", nil
+	}
+	return "'" + s + "'", nil
+}
+
+// intLiteral formats n, parenthesizing negative numbers so that a preceding
+// minus sign cannot turn them into a comment.
+func intLiteral(n int64) string {
+	if n < 0 {
+		return "(" + strconv.FormatInt(n, 10) + ")"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func floatLiteral(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8"
+	case math.IsInf(f, 1):
+		return "'Infinity'::float8"
+	case math.IsInf(f, -1):
+		return "'-Infinity'::float8"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if f < 0 {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	ts := time.Date(2024, 10, 17, 12, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		sql  string
+		args []any
+		want string
+	}{
+		{"SELECT $1, $2", []any{1, "a"}, "SELECT 1, 'a'"},
+		{"SELECT $1", []any{"it's"}, "SELECT 'it''s'"},
+		{"SELECT $1", []any{`C:\dir`}, `SELECT E'C:\\dir'`},
+		{"SELECT $1", []any{`\'; DROP TABLE t; --`}, `SELECT E'\\''; DROP TABLE t; --'`},
+		{"SELECT $1, $1", []any{nil}, "SELECT NULL, NULL"},
+		{"SELECT 1-$1", []any{-2}, "SELECT 1-(-2)"},
+		{"SELECT $1", []any{[]byte{0, 0xff}}, `SELECT '\x00ff'::bytea`},
+		{"SELECT $1", []any{ts}, "SELECT '2024-10-17 12:30:00Z'::timestamptz"},
+		{"SELECT '$1', \"$1\", $$ $1 $$, $1 -- $1", []any{true}, "SELECT '$1', \"$1\", $$ $1 $$, TRUE -- $1"},
+		{"SELECT E'\\'$1', $1", []any{false}, "SELECT E'\\'$1', FALSE"},
+	} {
+		got, err := interpolate(tc.sql, tc.args)
+		if err != nil {
+			t.Errorf("interpolate(%q): %v", tc.sql, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("interpolate(%q) = %q, want %q", tc.sql, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		sql  string
+		args []any
+	}{
+		{"SELECT $2", []any{1}},
+		{"SELECT $1", []any{struct{}{}}},
+		{"SELECT $1", []any{"nul\x00"}},
+	} {
+		if _, err := interpolate(tc.sql, tc.args); err == nil {
+			t.Errorf("interpolate(%q, %v): expected error", tc.sql, tc.args)
+		}
+	}
+}
+
+func TestQueryRowsParams(t *testing.T) {
+	res, err := testPG.QueryRowsParams("SELECT $1::text, $2::int + 1;", `quote ' and \ backslash`, 41)
+	if err != nil {
+		t.Fatalf("QueryRowsParams: %v", err)
+	}
+	if got := res.Rows[0]; got[0] != `quote ' and \ backslash` || got[1] != "42" {
+		t.Errorf("unexpected row: %q", got)
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/rand"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
@@ -19,189 +25,1324 @@ import (
 	"github.com/tetratelabs/wazero/sys"
 )
 
-var (
-	//go:embed pglite-wasi.tar.gz
-	compressed []byte
-)
+// maxStatementSize bounds the statements read from a script. It is well
+// above maxQuerySize, so that oversized statements are reported with
+// ErrQueryTooLarge.
+const maxStatementSize = 1 << 30
+
+// defaultWaitReady bounds Options.WaitReady when there is no InitTimeout.
+const defaultWaitReady = 10 * time.Second
+
+// ErrClosed is returned when an instance is used after Close, or after
+// its module was closed by a cancelled query.
+var ErrClosed = errors.New("pglite: instance is closed")
+
+// ErrQueryTooLarge is returned when a query, with its terminating NUL
+// byte, is longer than maxQuerySize, 8187 bytes: the backend reads each
+// message from the socket file in one go, into a buffer of 8 KiB, and
+// cuts longer ones short. Load large values with CopyFrom or
+// CopyFromStdin, whose data is sent in pieces.
+var ErrQueryTooLarge = errors.New("query too large for a message to the backend")
+
+// Options configures a PGLite instance.
+type Options struct {
+	// DataDir is the host directory the cluster is extracted into. Its tmp
+	// subdirectory is mounted at /tmp in the sandbox.
+	// If empty, the current directory is used.
+	//
+	// Instances on different data directories share nothing: each has its
+	// own /tmp, with the socket files the backend is reached through, and
+	// its own virtual /dev, so any number may run in one process. Two may
+	// not run on the same directory at once; see NewPGLiteTemp.
+	DataDir string
+
+	// TarballFS supplies the installation tarball, as the file
+	// pglite-wasi.tar.gz in it, instead of the copy embedded in the
+	// package, for programs that fetch it separately; build those with the
+	// pglite_noembed tag to leave the embedded copy out. It must be the
+	// tarball the package was built with, as the files extracted from it
+	// are checked against checksums embedded in the package, which also
+	// catches a corrupted download. Entries that would land outside
+	// DataDir, links pointing outside it and paths through existing
+	// symbolic links are refused. It is read only when DataDir needs
+	// extracting.
+	TarballFS fs.FS
+
+	// ResetOnStart discards any cluster already in DataDir and starts from
+	// a freshly extracted one. Otherwise an existing cluster, including
+	// any data written by a previous run, is reused.
+	ResetOnStart bool
+
+	// RuntimeConfig configures the wazero runtime. If nil, the default
+	// (compiler) config is used.
+	RuntimeConfig wazero.RuntimeConfig
+
+	// Extensions are created with CREATE EXTENSION IF NOT EXISTS once the
+	// backend is up. The embedded build ships only plpgsql, which is
+	// already installed; requesting anything else, such as vector, fails
+	// with an ExtensionError.
+	Extensions []string
+
+	// CheckpointOnClose makes Close run a CHECKPOINT before releasing the
+	// instance, as Shutdown does.
+	CheckpointOnClose bool
+
+	// User and Database are the role and database the session runs as,
+	// "postgres" if empty. If either does not exist yet, it is created on
+	// first start: the role as a superuser, the database owned by User.
+	User     string
+	Database string
+
+	// Locale is the locale of a database created for Database, if one is
+	// created. The embedded build supports only the C and POSIX locales.
+	Locale string
+
+	// ClientEncoding, if set, is the session's client_encoding.
+	ClientEncoding string
+
+	// MemoryLimitPages caps the module's linear memory, in 64 KiB WASM
+	// pages, overriding the limit in RuntimeConfig. If zero, the module may
+	// grow to the 4 GiB WASM maximum. When a query needs more memory than
+	// the limit allows, PostgreSQL's allocations fail and the query returns
+	// an "out of memory" QueryError (SQLSTATE 53200) instead of the host
+	// running out of memory.
+	//
+	// All of the backend's memory lives there: shared_buffers, allocated
+	// once at startup, plus work_mem for each sort or hash a query runs,
+	// plus caches. Allow comfortably more than shared_buffers and a few
+	// times work_mem, or the instance fails to start or to run ordinary
+	// queries. SHOW shared_buffers and SHOW work_mem give the settings.
+	MemoryLimitPages uint32
+
+	// InitTimeout, if not zero, bounds how long instantiating the module
+	// and booting the backend with pg_initdb may take. If it is exceeded,
+	// the start fails with an error matching context.DeadlineExceeded.
+	InitTimeout time.Duration
+
+	// WaitReady makes the start wait until the backend answers a trivial
+	// query, running it again until it succeeds, so that the instance is
+	// ready for queries once returned. The wait is bounded by InitTimeout,
+	// or by defaultWaitReady if that is zero.
+	WaitReady bool
+
+	// InMemory runs the instance in a new data directory on a
+	// memory-backed file system, /dev/shm, which is removed when the
+	// instance is closed. Where there is no /dev/shm, the system temporary
+	// directory is used instead. DataDir must be empty.
+	InMemory bool
+
+	// TempDir runs the instance in a new data directory created in
+	// TempDir, which is removed when the instance is closed, or if the
+	// start fails partway. DataDir must be empty, and InMemory unset. See
+	// NewPGLiteTemp.
+	TempDir string
+
+	// Mounts and ReadOnlyMounts map host directories to the absolute
+	// paths they appear at in the sandbox, so statements such as
+	// COPY ... FROM '/data/import.csv' can use host files directly. The
+	// sandbox can write to Mounts but not to ReadOnlyMounts. Guest paths
+	// may not be / or lie within /tmp or /dev, which the instance mounts
+	// itself.
+	Mounts         map[string]string
+	ReadOnlyMounts map[string]string
+
+	// ReadOnly serves the cluster in DataDir without letting the sandbox
+	// change anything in it, for query-only replicas. If DataDir holds no
+	// cluster yet, or ResetOnStart is set, one is first set up there by an
+	// instance that is then shut down; that is the only time the sandbox
+	// can write to DataDir. The instance itself runs on a private copy of
+	// the data directory, made on a memory-backed file system as for
+	// InMemory and removed when it is closed.
+	//
+	// This guards against the module, which runs PostgreSQL's C code and
+	// anything loaded into it, being made to damage or tamper with the
+	// host's files, whether through a bug or a hostile query: DataDir is
+	// never mounted in the sandbox, so no statement, setting or crash can
+	// reach it, and the only host directories it can see are
+	// ReadOnlyMounts. It is not a SQL permission check: statements that
+	// write still succeed, but their changes land in the copy and are
+	// lost on Close. Mounts, InMemory and TempDir cannot be combined with
+	// ReadOnly.
+	ReadOnly bool
+
+	// ReadOnlyQueries makes every transaction read-only, by setting
+	// default_transaction_read_only, so that statements which write, such
+	// as INSERT or CREATE TABLE, fail with SQLSTATE 25006
+	// (read_only_sql_transaction) and queries still run. The setting is
+	// restored whenever the backend restarts.
+	//
+	// It guards against mistakes, not hostile SQL: a statement can still
+	// change the setting, with SET or BEGIN READ WRITE, or call a function
+	// that writes outside a transaction's rules. For untrusted input,
+	// combine it with ReadOnly, so that whatever gets written is discarded.
+	ReadOnlyQueries bool
+
+	// Retry retries statements that fail for a transient reason, such as
+	// running out of memory under MemoryLimitPages. See RetryPolicy.
+	Retry RetryPolicy
+
+	// Logger receives diagnostics about the instance's own work, such as
+	// extracting the installation tree, what pg_initdb returned, each
+	// statement RunQueries runs and errors serving clients. If nil,
+	// warnings and errors are written to stderr and the rest discarded,
+	// unless Verbose is set.
+	Logger Logger
+
+	// Verbose, when Logger is nil, writes all the diagnostics Logger would
+	// receive to stderr. Those below warnings are of use when debugging the
+	// module, and left out by default.
+	Verbose bool
+
+	// VacuumInterval, if not zero, runs VACUUM ANALYZE on every table of the
+	// database at this interval, in the background, to keep tables from
+	// bloating and the planner's statistics current in long-running use.
+	// Each run waits its turn with other statements, and is skipped while a
+	// transaction is open; failures go to Logger. See Vacuum.
+	VacuumInterval time.Duration
+
+	// IdleTimeout, if not zero, suspends the instance once it has run no
+	// queries for this long, to release the memory of an idle one, as in a
+	// pool: it runs a CHECKPOINT and closes the module, keeping the data
+	// directory. The next query starts the module again, which takes as
+	// long as a start, and finds the session state lost as after a failed
+	// statement; see UseDatabase. The suspension waits its turn with other
+	// statements, and is put off while a transaction is open. See
+	// TouchedAt.
+	IdleTimeout time.Duration
+
+	// IdleSnapshot, if set with IdleTimeout, is called as the instance is
+	// suspended, and a snapshot is written to the writer it returns, as
+	// Snapshot writes, which is then closed. A failure goes to Logger and
+	// does not stop the suspension.
+	IdleSnapshot func() (io.WriteCloser, error)
+
+	// InsertBatchSize is the most rows InsertRows puts in one INSERT; if
+	// zero, 100.
+	InsertBatchSize int
+
+	// ExtraArgs are server options given to postgres after --single, which
+	// always comes first, and before the database name. Each sets a run-time
+	// parameter, as "-c name=value", "-cname=value" or "--name=value";
+	// other arguments, including a second mode such as --single or --boot,
+	// fail the start.
+	//
+	// The bundled build starts its backend with arguments of its own and
+	// ignores these, so each setting is also applied to the session once
+	// the backend is up, and again after every restart, as SetParam does.
+	// That works for parameters a session may change, such as work_mem or
+	// statement_timeout; one fixed when the server starts, such as
+	// shared_buffers, fails the start.
+	ExtraArgs []string
+
+	// StickySession, if the session is found not to persist from one call
+	// into the backend to the next, applies the settings made with
+	// SetParam again before each query, so that they hold as if it did.
+	// The bundled build's session persists, so it does nothing there; see
+	// SessionPersists.
+	StickySession bool
+}
 
 // PGLite wraps a PostgreSQL instance running via WebAssembly (wazero).
+//
+// The embedded backend runs a single session and takes its input from a
+// fixed region of WASM memory, so queries are serialized: concurrent calls
+// are safe but run one at a time.
 type PGLite struct {
-	runtime wazero.Runtime
+	// mu serializes access to the module's socket files and output.
+	mu     sync.Mutex
+	closed bool
+
+	runtime wazero.Runtime // nil if the runtime is shared
 	mod     api.Module
 	ctx     context.Context
 	stdout  io.Writer
 	stderr  io.Writer
+	log     Logger
+	dataDir string
+
+	// inAddr locates the buffer in linear memory that the module reads a
+	// query from, which is left empty so that it reads the socket file.
+	inAddr uint32
+
+	// outOut and errOut are the writers handed to the module as its stdout
+	// and stderr. They forward to stdout and stderr, except that errOut is
+	// redirected while a query captures its output.
+	outOut *switchWriter
+	errOut *switchWriter
+
+	onQuery  func(sql string, d time.Duration, err error)
+	onNotice func(level, msg string)
+
+	checkpointOnClose bool
+	retry             RetryPolicy
+	removeDir         string                         // removed on close, for InMemory, TempDir and ReadOnly instances
+	listeners         []net.Listener                 // opened by ServeTCP, closed on close
+	listening         map[string][]chan Notification // by channel name, set by Listen
+	stopVacuum        chan struct{}                  // closed on close, with VacuumInterval
+	stopIdle          chan struct{}                  // closed on close, with IdleTimeout
+	version           string                         // cached by ServerVersion
+	params            map[string]string              // set by SetParam, re-applied on restart
+	prepared          map[string]string              // PREPARE statements by name, from Prepare, run again on restart
+	stats             Stats
+
+	// decoders holds the decoders registered by RegisterType, by type OID.
+	decoders map[uint32]func(string) (any, error)
+
+	// touched is when the backend last replied; see TouchedAt. suspended
+	// is set while the module is closed for IdleTimeout.
+	touched   time.Time
+	suspended bool
+
+	// sessionPersists is what the start found for SessionPersists.
+	// replaying is set while StickySession applies settings again.
+	sessionPersists bool
+	replaying       bool
+
+	// restarts counts the backend's restarts, and restarting is set while
+	// one restores the session. While a transaction begun by Begin or
+	// WithRollback is open, txOpen is set and txRestarts holds restarts as
+	// it began, so that one rolled back by a restart since is found; see
+	// txAborted.
+	restarts   uint64
+	restarting bool
+	txOpen     bool
+	txRestarts uint64
+
+	// exitCode is the code the module exited with, if exited is set.
+	// fault is the error from a query that crashed the module: an exit
+	// with a non-zero code or a trap.
+	exited   bool
+	exitCode uint32
+	fault    error
+
+	// compiled and opts started the instance, for UseDatabase and error
+	// recovery to start it again. compiled is nil until the start is
+	// complete.
+	compiled *compiledModule
+	opts     Options
+}
+
+// switchWriter forwards writes to a target that can be replaced while the
+// module is running. If tail is set, it also keeps the end of what was
+// written there, whatever the target.
+type switchWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	tail *tailBuffer
+}
+
+func (s *switchWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tail != nil {
+		s.tail.Write(b)
+	}
+	return s.w.Write(b)
+}
+
+// swap sets the target writer and returns the previous one.
+func (s *switchWriter) swap(w io.Writer) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.w
+	s.w = w
+	return prev
+}
+
+// tailBuffer keeps the last tailBufferSize bytes written to it.
+type tailBuffer struct {
+	b []byte
+}
+
+const tailBufferSize = 64 << 10
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.b = append(t.b, b...)
+	if over := len(t.b) - tailBufferSize; over > 0 {
+		t.b = append(t.b[:0], t.b[over:]...)
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.b)
 }
 
 // NewPGLite creates and initializes a PGLite instance. The stdout and stderr
 // writers receive PostgreSQL output. Note: the PGLite WASM module redirects
 // query output to stderr. An optional wazero.RuntimeConfig can be provided;
-// if nil, the default (compiler) config is used. The caller must call Close
-// when done.
+// if nil, the default (compiler) config is used, falling back to the
+// interpreter if compilation fails, with a warning written to stderr. The
+// caller must call Close when done.
 func NewPGLite(ctx context.Context, stdout, stderr io.Writer, rtConfig ...wazero.RuntimeConfig) (*PGLite, error) {
-	blob, err := setupEnv()
-	if err != nil {
+	var opts Options
+	if len(rtConfig) > 0 {
+		opts.RuntimeConfig = rtConfig[0]
+	}
+	return NewPGLiteWithOptions(ctx, stdout, stderr, opts)
+}
+
+// NewPGLiteInterpreter is like NewPGLite but runs the module in wazero's
+// interpreter, for platforms where the compiler is unavailable, such as
+// those that forbid executable memory. Queries run considerably slower.
+func NewPGLiteInterpreter(ctx context.Context, stdout, stderr io.Writer) (*PGLite, error) {
+	return NewPGLite(ctx, stdout, stderr, wazero.NewRuntimeConfigInterpreter())
+}
+
+// NewPGLiteWithOptions is like NewPGLite but takes its configuration from
+// opts.
+func NewPGLiteWithOptions(ctx context.Context, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	return readOnlyCopy(ctx, opts, func(opts Options) (*PGLite, error) {
+		return tempDataDir(opts, func(opts Options) (*PGLite, error) {
+			return newPGLiteWithOptions(ctx, stdout, stderr, opts)
+		})
+	})
+}
+
+func newPGLiteWithOptions(ctx context.Context, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	dataDir := opts.dataDir()
+	if err := opts.reset(); err != nil {
+		return nil, err
+	}
+	if err := extractEnv(ctx, dataDir, opts.TarballFS, opts.logger(stderr)); err != nil {
 		return nil, fmt.Errorf("setupEnv: %w", err)
 	}
 
-	var r wazero.Runtime
-	if len(rtConfig) > 0 && rtConfig[0] != nil {
-		r = wazero.NewRuntimeWithConfig(ctx, rtConfig[0])
-	} else {
-		r = wazero.NewRuntime(ctx)
+	blob, err := os.ReadFile(filepath.Join(dataDir, "tmp/pglite/bin/postgres.wasi"))
+	if err != nil {
+		return nil, err
+	}
+	return newPGLite(ctx, blob, stdout, stderr, opts)
+}
+
+// NewPGLiteFromWASM is like NewPGLiteWithOptions but runs the given
+// postgres WASM binary instead of the embedded one. Nothing is extracted:
+// opts.DataDir must already hold the PGLite installation tree, either one
+// prepared with ExtractEnv or a build's own. If the tree has no initialized
+// cluster, pg_initdb creates one. opts.InMemory and opts.TempDir are not
+// supported.
+func NewPGLiteFromWASM(ctx context.Context, wasm []byte, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	if opts.InMemory || opts.TempDir != "" {
+		return nil, fmt.Errorf("NewPGLiteFromWASM: InMemory and TempDir: %w", ErrNotSupported)
+	}
+	return readOnlyCopy(ctx, opts, func(opts Options) (*PGLite, error) {
+		if err := opts.reset(); err != nil {
+			return nil, err
+		}
+		return newPGLite(ctx, wasm, stdout, stderr, opts)
+	})
+}
+
+func (o Options) dataDir() string {
+	if o.DataDir == "" {
+		return "."
+	}
+	return o.DataDir
+}
+
+func (o Options) user() string {
+	if o.User == "" {
+		return "postgres"
+	}
+	return o.User
+}
+
+func (o Options) database() string {
+	if o.Database == "" {
+		return "postgres"
+	}
+	return o.Database
+}
+
+// logger returns Logger, or if it is nil a Logger writing to stderr:
+// everything if Verbose is set, and only warnings and errors if not.
+func (o Options) logger(stderr io.Writer) Logger {
+	switch {
+	case o.Logger != nil:
+		return o.Logger
+	case o.Verbose:
+		return NewLogger(stderr)
+	}
+	return quietLogger{NewLogger(stderr)}
+}
+
+// reset removes the cluster if ResetOnStart is set.
+func (o Options) reset() error {
+	if !o.ResetOnStart {
+		return nil
+	}
+	if err := os.RemoveAll(filepath.Join(o.dataDir(), "tmp/pglite/base")); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	return nil
+}
+
+// parseExtraArgs returns the name and value of each setting in args, as
+// Options.ExtraArgs describes them.
+func parseExtraArgs(args []string) ([][2]string, error) {
+	var settings [][2]string
+	for i := 0; i < len(args); i++ {
+		var kv string
+		switch arg := args[i]; {
+		case arg == "-c" && i+1 < len(args):
+			i++
+			kv = args[i]
+		case strings.HasPrefix(arg, "-c") && arg != "-c":
+			kv = arg[2:]
+		case strings.HasPrefix(arg, "--") && strings.Contains(arg, "="):
+			// postgres takes dashes in long option names for underscores.
+			name, value, _ := strings.Cut(arg[2:], "=")
+			kv = strings.ReplaceAll(name, "-", "_") + "=" + value
+		default:
+			return nil, fmt.Errorf("extra args: unsupported argument %q; only settings are allowed", arg)
+		}
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("extra args: %q is not name=value", kv)
+		}
+		settings = append(settings, [2]string{name, value})
+	}
+	return settings, nil
+}
+
+// sharedCache lets runtimes created with the default config reuse each
+// other's compilation of the module.
+var sharedCache = wazero.NewCompilationCache()
+
+// newPGLite starts blob on the data directory and boots the backend, in a
+// runtime of its own.
+func newPGLite(ctx context.Context, blob []byte, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	m, err := compile(ctx, blob, opts.RuntimeConfig, opts.MemoryLimitPages, opts.logger(stderr))
+	if err != nil {
+		return nil, err
 	}
+	p, err := m.instantiate(ctx, stdout, stderr, opts)
+	if err != nil {
+		m.runtime.Close(ctx)
+		return nil, err
+	}
+	p.runtime = m.runtime
+	return p, nil
+}
 
-	fsConfig := wazero.NewFSConfig().
-		WithDirMount("./tmp", "/tmp").
-		WithDirMount("./dev", "/dev")
+// compiledModule is a postgres module compiled in a runtime, ready to be
+// instantiated.
+type compiledModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	inAddr   uint32
+}
+
+// compile creates a runtime from rtConfig and compiles blob in it. If
+// memoryLimitPages is not zero, it limits the memory of the module's
+// instances. If rtConfig is nil, the default config is used, and if that
+// fails the interpreter is tried, with a warning logged to log.
+func compile(ctx context.Context, blob []byte, rtConfig wazero.RuntimeConfig, memoryLimitPages uint32, log Logger) (*compiledModule, error) {
+	if rtConfig != nil {
+		return compileWith(ctx, blob, rtConfig, memoryLimitPages)
+	}
+	m, err := compileWith(ctx, blob, wazero.NewRuntimeConfig().WithCompilationCache(sharedCache), memoryLimitPages)
+	if err == nil {
+		return m, nil
+	}
+	log.Warnf("%v; falling back to the interpreter", err)
+	return compileWith(ctx, blob, wazero.NewRuntimeConfigInterpreter(), memoryLimitPages)
+}
+
+func compileWith(ctx context.Context, blob []byte, rtConfig wazero.RuntimeConfig, memoryLimitPages uint32) (*compiledModule, error) {
+	inAddr, _, err := inputBuffer(blob)
+	if err != nil {
+		return nil, fmt.Errorf("input buffer: %w", err)
+	}
+
+	if memoryLimitPages > 0 {
+		rtConfig = rtConfig.WithMemoryLimitPages(memoryLimitPages)
+	}
+	// Close the module when a query's context is done so that cancellation
+	// interrupts the running statement.
+	r := wazero.NewRuntimeWithConfig(ctx, rtConfig.WithCloseOnContextDone(true))
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	compiled, err := r.CompileModule(ctx, patchReThrow(blob))
+	if err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("compile: %w", err)
+	}
+	return &compiledModule{
+		runtime:  r,
+		compiled: compiled,
+		inAddr:   inAddr,
+	}, nil
+}
+
+// instantiate starts an instance of the module on the data directory in
+// opts, once checkDataDir finds nothing wrong with it, boots the backend
+// and starts the background work opts asks for. The returned PGLite does
+// not own the runtime.
+func (m *compiledModule) instantiate(ctx context.Context, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	if err := checkDataDir(opts.dataDir()); err != nil {
+		return nil, err
+	}
+	p, err := m.startAs(ctx, stdout, stderr, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if err = p.probeSession(); err != nil {
+		p.close(ctx)
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("probe session: %w", err)
+	}
+	if opts.VacuumInterval > 0 {
+		p.stopVacuum = make(chan struct{})
+		go p.vacuumEvery(opts.VacuumInterval, p.stopVacuum)
+	}
+	p.touched = time.Now()
+	if opts.IdleTimeout > 0 {
+		p.stopIdle = make(chan struct{})
+		go p.suspendWhenIdle(opts.IdleTimeout, p.stopIdle)
+	}
+	runtime.SetFinalizer(p, (*PGLite).finalize)
+	if instanceCreated != nil {
+		instanceCreated(p)
+	}
+	return p, nil
+}
+
+// instanceCreated and instanceLeaked, if set, are called with each
+// instance instantiate creates and with the data directory of each that
+// finalize finds was not closed, so that tests can check all are closed.
+var (
+	instanceCreated func(*PGLite)
+	instanceLeaked  func(dataDir string)
+)
+
+// finalize warns, through the Logger or else on stderr, of an instance
+// that was garbage collected without being closed, leaking its runtime.
+// One running background work, such as VacuumInterval, is never
+// collected.
+func (p *PGLite) finalize() {
+	if p.closed {
+		return
+	}
+	p.log.Warnf("instance on %s was not closed; call Close to release it", p.dataDir)
+	if instanceLeaked != nil {
+		instanceLeaked(p.dataDir)
+	}
+}
+
+// startAs is start, except that if the backend fails to start as opts.User
+// on opts.Database, which may not exist yet, they are created from a
+// session as postgres and the start is retried.
+func (m *compiledModule) startAs(ctx context.Context, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	p, err := m.start(ctx, stdout, stderr, opts)
+	if err == nil || (opts.user() == "postgres" && opts.database() == "postgres") {
+		return p, err
+	}
+	created, berr := m.bootstrap(ctx, stdout, stderr, opts)
+	if berr != nil {
+		return nil, berr
+	}
+	if !created {
+		return nil, err
+	}
+	return m.start(ctx, stdout, stderr, opts)
+}
+
+// bootstrap creates opts.User and opts.Database if they are missing and
+// reports whether it created either.
+func (m *compiledModule) bootstrap(ctx context.Context, stdout, stderr io.Writer, opts Options) (bool, error) {
+	p, err := m.start(ctx, stdout, stderr, Options{DataDir: opts.DataDir})
+	if err != nil {
+		return false, fmt.Errorf("bootstrap: %w", err)
+	}
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.close(ctx)
+	}()
+
+	exists := func(query, name string) (bool, error) {
+		res, err := p.QueryRowsParams(query, name)
+		if err != nil {
+			return false, fmt.Errorf("bootstrap: %w", err)
+		}
+		return len(res.Rows) > 0, nil
+	}
+
+	created := false
+	user := opts.user()
+	ok, err := exists("SELECT 1 FROM pg_roles WHERE rolname = $1;", user)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		if err := p.Query("CREATE ROLE " + quoteIdent(user) + " SUPERUSER LOGIN;"); err != nil {
+			return false, fmt.Errorf("bootstrap: create role: %w", err)
+		}
+		created = true
+	}
+
+	db := opts.database()
+	ok, err = exists("SELECT 1 FROM pg_database WHERE datname = $1;", db)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		q := "CREATE DATABASE " + quoteIdent(db) + " OWNER " + quoteIdent(user)
+		if opts.Locale != "" {
+			locale, err := quoteString(opts.Locale)
+			if err != nil {
+				return false, fmt.Errorf("bootstrap: locale: %w", err)
+			}
+			q += " TEMPLATE template0 LOCALE " + locale
+		}
+		if err := p.Query(q + ";"); err != nil {
+			return false, fmt.Errorf("bootstrap: create database: %w", err)
+		}
+		created = true
+	}
+	return created, nil
+}
+
+// start instantiates the module and boots the backend.
+func (m *compiledModule) start(ctx context.Context, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	dataDir := opts.dataDir()
+	fsConfig, err := opts.fsConfig()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := parseExtraArgs(opts.ExtraArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep what the module prints while starting, for the error if it
+	// fails.
+	var startLog tailBuffer
+	outOut := &switchWriter{w: io.MultiWriter(stdout, &startLog)}
+	errOut := &switchWriter{w: io.MultiWriter(stderr, &startLog), tail: &tailBuffer{}}
+
+	initCtx := ctx
+	if opts.InitTimeout > 0 {
+		var cancel context.CancelFunc
+		initCtx, cancel = context.WithTimeout(ctx, opts.InitTimeout)
+		defer cancel()
+	}
+
+	// When ctx is done or InitTimeout passes, the runtime closes the
+	// module, and the start fails with ctx's error or a timeout rather
+	// than whatever the interrupted call returned.
+	var mod api.Module
+	fail := func(err error) (*PGLite, error) {
+		if mod != nil {
+			mod.Close(ctx)
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if initCtx.Err() != nil {
+			err = fmt.Errorf("timed out after %v: %w", opts.InitTimeout, context.DeadlineExceeded)
+		}
+		return nil, &StartError{Err: err, Log: startLog.String()}
+	}
 
 	config := wazero.NewModuleConfig().
-		WithStdout(stdout).
-		WithStderr(stderr).
+		WithStdout(outOut).
+		WithStderr(errOut).
 		WithFSConfig(fsConfig)
 
-	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	// Instances are anonymous so that several can share a runtime.
 
-	mod, err := r.InstantiateWithConfig(
-		ctx,
-		blob,
+	mod, err = m.runtime.InstantiateModule(
+		initCtx,
+		m.compiled,
 		config.
-			WithArgs("--single", "postgres").
+			WithName("").
+			WithArgs(append(append([]string{"--single"}, opts.ExtraArgs...), "postgres")...).
 			WithEnv("ENVIRONMENT", "wasi-embed").
 			WithEnv("REPL", "N").
-			WithEnv("PGUSER", "postgres").
-			WithEnv("PGDATABASE", "postgres"),
+			WithEnv("PGUSER", opts.user()).
+			WithEnv("PGDATABASE", opts.database()),
 	)
 	if err != nil {
 		if exitErr, ok := err.(*sys.ExitError); ok && exitErr.ExitCode() != 0 {
-			r.Close(ctx)
-			return nil, fmt.Errorf("wasm exit_code: %d", exitErr.ExitCode())
+			return fail(fmt.Errorf("wasm exit_code: %d", exitErr.ExitCode()))
 		} else if !ok {
-			r.Close(ctx)
-			return nil, fmt.Errorf("instantiate: %w", err)
+			return fail(fmt.Errorf("instantiate: %w", err))
 		}
 	}
 
 	p := &PGLite{
-		runtime: r,
 		mod:     mod,
 		ctx:     ctx,
 		stdout:  stdout,
 		stderr:  stderr,
+		log:     opts.logger(stderr),
+		dataDir: dataDir,
+		inAddr:  m.inAddr,
+		outOut:  outOut,
+		errOut:  errOut,
+
+		checkpointOnClose: opts.CheckpointOnClose,
+		retry:             opts.Retry,
+		opts:              opts,
 	}
 
-	initDBRV, err := mod.ExportedFunction("pg_initdb").Call(ctx)
+	// pg_initdb boots the backend, first initializing the cluster if the
+	// data directory does not hold one.
+	initDBRV, err := mod.ExportedFunction("pg_initdb").Call(initCtx)
 	if err != nil {
-		r.Close(ctx)
-		return nil, fmt.Errorf("pg_initdb: %w", err)
+		return fail(fmt.Errorf("pg_initdb: %w", err))
 	}
-	fmt.Fprintf(stderr, "initdb returned: %b\n", initDBRV)
+	opts.logger(stderr).Debugf("initdb returned: %b", initDBRV)
 
 	_, err = mod.ExportedFunction("use_socketfile").Call(ctx)
 	if err != nil {
-		r.Close(ctx)
-		return nil, fmt.Errorf("use_socketfile: %w", err)
+		return fail(fmt.Errorf("use_socketfile: %w", err))
 	}
 
+	// Have error reports include the SQLSTATE, for QueryError.Code.
+	if err := p.Query("SET log_error_verbosity = verbose;"); err != nil {
+		return fail(fmt.Errorf("configure session: %w", err))
+	}
+	// pg_initdb leaves the session with the search_path initdb uses.
+	if err := p.Query(`SET search_path = "$user", public;`); err != nil {
+		return fail(fmt.Errorf("configure session: %w", err))
+	}
+	for _, kv := range settings {
+		q, err := interpolate("SELECT set_config($1, $2, false);", []any{kv[0], kv[1]})
+		if err == nil {
+			err = p.Query(q)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("extra args: set %s: %w", kv[0], err))
+		}
+	}
+	if opts.ClientEncoding != "" {
+		enc, err := quoteString(opts.ClientEncoding)
+		if err == nil {
+			err = p.Query("SET client_encoding = " + enc + ";")
+		}
+		if err != nil {
+			return fail(fmt.Errorf("configure session: %w", err))
+		}
+	}
+	if err := p.createExtensions(opts.Extensions); err != nil {
+		return fail(err)
+	}
+	// Last, as creating the extensions writes.
+	if opts.ReadOnlyQueries {
+		if err := p.Query("SET default_transaction_read_only = on;"); err != nil {
+			return fail(fmt.Errorf("configure session: %w", err))
+		}
+	}
+	if opts.WaitReady {
+		waitCtx := initCtx
+		if opts.InitTimeout == 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, defaultWaitReady)
+			defer cancel()
+		}
+		if err := p.waitReady(waitCtx); err != nil {
+			return fail(err)
+		}
+	}
+
+	outOut.swap(stdout)
+	errOut.swap(stderr)
+	p.compiled = m
 	return p, nil
 }
 
 // Query executes a SQL statement. Output is written to the configured stderr
 // writer (the PGLite WASM module directs query output to stderr).
+//
+// sql may hold several statements separated by semicolons. They are sent
+// together, as one simple-protocol query, and run in order, as a single
+// implicit transaction unless sql has BEGIN and COMMIT of its own: the
+// first to fail stops the rest, its error is returned, and what the
+// statements before it did in that transaction is rolled back. To run
+// statements one at a time, each in its own transaction, or to find which
+// one failed, use RunQueries or ExecBatch.
+//
+// A statement that fails leaves the backend unusable, so it is restarted,
+// which ends the session: temporary tables, settings made with SET and an
+// open transaction are lost. Settings made with SetParam, channels
+// listened on with Listen and statements prepared with Prepare are
+// restored in the new session; see SessionPersists.
 func (p *PGLite) Query(sql string) error {
-	sqlCstring := append([]byte(sql), 0)
-	p.mod.Memory().Write(1, sqlCstring)
+	return p.QueryContext(p.ctx, sql)
+}
 
-	_, err := p.mod.ExportedFunction("interactive_one").Call(p.ctx)
-	return err
+// QueryContext is like Query but runs the statement under ctx. If ctx is
+// cancelled or its deadline passes, the WASM execution is interrupted and
+// the module is closed, so the instance cannot be used for further queries.
+func (p *PGLite) QueryContext(ctx context.Context, sql string) error {
+	return p.retry.retry(ctx, sql, func() error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.query(ctx, sql, nil, nil)
+	})
 }
 
-// RunQueries splits input on blank lines and executes each non-empty query.
-func (p *PGLite) RunQueries(input string) error {
-	for _, line := range strings.Split(input, "\n\n") {
-		if trimmed := strings.TrimSpace(line); trimmed != "" {
-			fmt.Fprintf(p.stderr, "REPL: %s\n", line)
-			if err := p.Query(line); err != nil {
+// QueryAsync starts sql running as Query does and returns at once. done
+// receives the statement's error, or nil, and is then closed. cancel
+// stops the statement: if it has not started, because another statement
+// is running, it never runs and done receives context.Canceled, and the
+// instance is unaffected. If it is running, the WASM execution is
+// interrupted, as for QueryContext, and done receives context.Canceled;
+// this closes the module, so the instance cannot be used again, with
+// later calls failing with ErrClosed, and must be closed and created
+// anew. Calling cancel after done has received does nothing.
+func (p *PGLite) QueryAsync(sql string) (cancel func(), done <-chan error) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		ch <- p.retry.retry(ctx, sql, func() error {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err := ctx.Err(); err != nil {
 				return err
 			}
-		}
-	}
-	return nil
+			return p.query(ctx, sql, nil, nil)
+		})
+	}()
+	return cancel, ch
 }
 
-// Close releases all resources held by the PGLite instance.
-func (p *PGLite) Close() {
-	if p.runtime != nil {
-		p.runtime.Close(p.ctx)
+// QueryTo is like Query but writes the statement's output to w instead of
+// the configured stderr writer.
+func (p *PGLite) QueryTo(w io.Writer, sql string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.query(p.ctx, sql, w, nil)
+}
+
+// SetOutput replaces the writers the module's stdout and stderr go to,
+// which were set when the instance was created. It waits for a running
+// query to finish. A nil writer discards output.
+func (p *PGLite) SetOutput(stdout, stderr io.Writer) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stdout, p.stderr = stdout, stderr
+	p.outOut.swap(stdout)
+	p.errOut.swap(stderr)
+}
+
+// OnQuery registers fn to be called after each statement sent to the
+// backend, with the time it took and the error it returned, if any. This
+// includes the statements Query, Exec, RunQueries and the other methods
+// issue on the caller's behalf, such as the transaction status query of
+// Begin, and those Validate checks. fn is called with the instance locked, so it must not use the
+// instance. A nil fn removes the hook.
+func (p *PGLite) OnQuery(fn func(sql string, d time.Duration, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onQuery = fn
 }
 
-func setupEnv() ([]byte, error) {
-	if _, err := os.Stat("./tmp/pglite/base/PG_VERSION"); err != nil {
-		fmt.Println("Extracting env....")
-		gr, err := gzip.NewReader(bytes.NewReader(compressed))
+// query runs sql, redirecting the module's stderr to w if it is not nil.
+// The rows it returns go to rows, or are printed to w if rows is nil. If
+// PostgreSQL reports an error, it is returned as a *QueryError, as it is
+// without running sql if the open transaction was rolled back by a
+// restart. The caller must hold p.mu.
+func (p *PGLite) query(ctx context.Context, sql string, w io.Writer, rows rowHandler) error {
+	if err := p.txAborted(); err != nil {
+		return err
+	}
+	if p.opts.StickySession && !p.sessionPersists && !p.replaying {
+		p.replaying = true
+		err := p.applyParams()
+		p.replaying = false
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("sticky session: %w", err)
 		}
-		defer gr.Close()
+	}
+	var err error
+	if p.onQuery == nil {
+		err = p.run(ctx, sql, w, rows)
+	} else {
+		start := time.Now()
+		err = p.run(ctx, sql, w, rows)
+		p.onQuery(sql, time.Since(start), err)
+	}
+	p.stats.Queries++
+	if err != nil {
+		p.stats.Errors++
+	}
+	return err
+}
+
+// run runs sql for query, sending it to the backend as a simple Query
+// message, or for a parseOnly as a Parse and a Sync, and reading the
+// reply.
+func (p *PGLite) run(ctx context.Context, sql string, w io.Writer, rows rowHandler) error {
+	if err := p.resume(); err != nil {
+		return err
+	}
+	if p.closed || p.mod.IsClosed() {
+		return ErrClosed
+	}
+	if w == nil {
+		w = p.stderr
+	}
+	if rows == nil {
+		rows = &rowPrinter{w: w}
+	}
+	prev := p.errOut.swap(w)
+	defer p.errOut.swap(prev)
 
-		tr := tar.NewReader(gr)
+	body := append([]byte(sql), 0)
+	if _, ok := rows.(parseOnly); ok {
+		// The unnamed statement, with no parameter types given.
+		body = append(append([]byte{0}, body...), 0, 0)
+	}
+	if n := len(body); n > maxQuerySize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrQueryTooLarge, n, maxQuerySize)
+	}
+	if err := unsupported(sql); err != nil {
+		return err
+	}
+	msgs := appendMessage(nil, 'Q', body)
+	if _, ok := rows.(parseOnly); ok {
+		msgs = appendMessage(appendMessage(nil, 'P', body), 'S', nil)
+	}
 
-		for {
-			header, err := tr.Next()
-			if err == io.EOF {
-				break
+	var qerr error
+	var nrows int
+	ready := false
+	start := time.Now()
+	var copyIn io.Reader
+	if f, ok := rows.(*copyFeed); ok {
+		copyIn = f.data
+	}
+	err := p.exchange(ctx, msgs, copyIn, func(typ byte, msg []byte) error {
+		body := msg[5:]
+		switch typ {
+		case 'T': // RowDescription
+			return rows.describe(body)
+		case 'D': // DataRow
+			nrows++
+			return rows.dataRow(body)
+		case 'C': // CommandComplete
+			if th, ok := rows.(tagHandler); ok {
+				th.commandComplete(string(bytes.TrimRight(body, "\x00")))
 			}
-			if err != nil {
-				return nil, err
+		case 'Z': // ReadyForQuery
+			ready = true
+		case 'E': // ErrorResponse
+			if qerr == nil {
+				qerr = parseErrorResponse(body)
 			}
-
-			dest := filepath.Join("./", header.Name)
-
-			switch header.Typeflag {
-			case tar.TypeDir:
-				if err := os.MkdirAll(dest, os.FileMode(header.Mode)); err != nil {
-					return nil, err
-				}
-			case tar.TypeReg:
-				if err := os.MkdirAll(filepath.Dir(dest), os.FileMode(header.Mode)); err != nil {
-					return nil, err
-				}
-
-				of, err := os.Create(dest)
-				if err != nil {
-					return nil, err
-				}
-				defer of.Close()
-
-				if _, err := io.Copy(of, tr); err != nil {
-					return nil, err
-				}
-			case tar.TypeSymlink:
-				if err := os.Symlink(header.Linkname, dest); err != nil {
-					return nil, err
-				}
-			default:
-				return nil, fmt.Errorf("unknown file type in tar: %c (%s)", header.Typeflag, header.Name)
+		case 'N': // NoticeResponse
+			if p.onNotice != nil {
+				n := parseErrorResponse(body)
+				p.onNotice(n.Severity, n.Message)
 			}
 		}
+		return nil
+	})
+	p.stats.WASMTime += time.Since(start)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &TruncatedError{Rows: nrows, Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	if qerr != nil {
+		return qerr
+	}
+	// The reply always ends with ReadyForQuery.
+	if !ready {
+		return &TruncatedError{Rows: nrows}
 	}
+	return nil
+}
 
-	if err := os.MkdirAll("./dev", 0755); err != nil {
-		return nil, err
+// callFailed records how a call into the module failed. The caller must
+// hold p.mu.
+func (p *PGLite) callFailed(err error) {
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		p.exited, p.exitCode = true, exitErr.ExitCode()
+		switch exitErr.ExitCode() {
+		case 0, sys.ExitCodeContextCanceled, sys.ExitCodeDeadlineExceeded:
+			return // not a crash
+		}
+	}
+	if p.fault == nil {
+		p.fault = err
 	}
+}
+
+// LastOutput returns the end of what the module has written to stderr,
+// up to the last 64 KiB: PostgreSQL's log messages, including those
+// written while starting and across restarts. It is kept whatever stderr
+// writer was given, as context for failures that QueryError does not
+// explain.
+func (p *PGLite) LastOutput() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errOut.tail.String()
+}
+
+// LastExitCode returns the code the module exited with and true, or false
+// if it has not exited. The module exits when PostgreSQL hits a FATAL
+// error, with code 1, or when a query's context is done, with
+// sys.ExitCodeContextCanceled or sys.ExitCodeDeadlineExceeded. Close does
+// not make it exit.
+func (p *PGLite) LastExitCode() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return int(p.exitCode), p.exited
+}
+
+// RunQueries splits input into statements and executes each in turn. It
+// splits at semicolons, ignoring those inside string literals, quoted
+// identifiers, dollar-quoted strings and comments. It stops at the first
+// statement that fails and returns a *ScriptError locating it, with no
+// File.
+func (p *PGLite) RunQueries(input string) error {
+	return p.RunQueriesFromReader(strings.NewReader(input))
+}
 
-	rf, err := os.Create("./dev/urandom")
+// RunQueriesFromReader is like RunQueries but reads its input from r as it
+// goes, so a large script need not be held in memory.
+func (p *PGLite) RunQueriesFromReader(r io.Reader) error {
+	sc := newLineScanner(r)
+	for index := 1; sc.Scan(); {
+		q := sc.Text()
+		if q == "" {
+			continue
+		}
+		p.log.Debugf("REPL: %s", q)
+		if err := p.Query(q); err != nil {
+			return &ScriptError{Index: index, Line: sc.line, Statement: q, Err: err}
+		}
+		index++
+	}
+	return sc.Err()
+}
+
+// Close releases all resources held by the PGLite instance. It waits for a
+// running query to finish. Calling Close more than once is safe.
+//
+// Data written by committed transactions survives Close, but the backend is
+// not shut down cleanly, so the next start replays the WAL written since the
+// last checkpoint. Use Shutdown, or set Options.CheckpointOnClose, to
+// checkpoint first.
+//
+// Close returns an error if the module crashed while in use, by exiting
+// with a non-zero code or trapping, or if releasing it fails. Later calls
+// return nil.
+func (p *PGLite) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	var errs []error
+	if p.checkpointOnClose && !p.suspended {
+		if err := p.query(p.ctx, "CHECKPOINT;", nil, nil); err != nil && p.fault == nil {
+			errs = append(errs, fmt.Errorf("checkpoint: %w", err))
+		}
+	}
+	if p.fault != nil {
+		errs = append(errs, fmt.Errorf("module crashed: %w", p.fault))
+	}
+	if err := p.close(p.ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown runs a CHECKPOINT, flushing all data to the cluster's files, and
+// then closes the instance. The single-user backend has no way to stop
+// short of exiting, which the module cannot do while embedded, so this is
+// as close to a clean shutdown as it gets: the next start finds nothing to
+// replay. The instance is closed even if the checkpoint fails. Shutdown of
+// a closed instance does nothing.
+func (p *PGLite) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	var err error
+	if !p.suspended { // checkpointed as it was suspended
+		err = p.query(ctx, "CHECKPOINT;", nil, nil)
+	}
+	if cerr := p.close(ctx); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("shutdown: %w", err)
 	}
-	defer rf.Close()
+	return nil
+}
 
-	rng := make([]byte, 128)
-	if _, err := rand.Read(rng); err != nil {
-		return nil, err
+// close releases the module or runtime. The caller must hold p.mu.
+func (p *PGLite) close(ctx context.Context) error {
+	p.closed = true
+	for _, l := range p.listeners {
+		l.Close()
+	}
+	p.listeners = nil
+	p.stopListening()
+	if p.stopVacuum != nil {
+		close(p.stopVacuum)
+		p.stopVacuum = nil
+	}
+	if p.stopIdle != nil {
+		close(p.stopIdle)
+		p.stopIdle = nil
+	}
+	var err error
+	if p.runtime != nil {
+		err = p.runtime.Close(ctx)
+	} else if p.mod != nil {
+		err = p.mod.Close(ctx)
+	}
+	if p.removeDir != "" {
+		if rerr := os.RemoveAll(p.removeDir); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// ExtractEnv extracts the embedded PGLite installation tree, including an
+// initialized cluster, into dataDir. It does nothing if dataDir already
+// holds a complete tree and cluster. Otherwise it restores whatever is
+// missing, and, if no extraction has completed in dataDir before, checks
+// the files present against checksums embedded with the tree and replaces
+// any that differ; see installEnv. Concurrent calls for the same
+// directory, from this or other processes, wait for a single extraction.
+func ExtractEnv(dataDir string) error {
+	return extractEnv(context.Background(), dataDir, nil, nopLogger{})
+}
+
+// ExtractEnvFS is like ExtractEnv but reads the installation tarball from
+// fsys, as Options.TarballFS describes.
+func ExtractEnvFS(dataDir string, fsys fs.FS) error {
+	return extractEnv(context.Background(), dataDir, fsys, nopLogger{})
+}
+
+// extractMu serializes extraction within the process, where file locks
+// are not available.
+var extractMu sync.Mutex
+
+// extractEnv is ExtractEnv, reading the tarball from src, or the embedded
+// one if src is nil, and logging an extraction to log. If ctx is done
+// during the extraction, it stops and returns ctx.Err(); see installEnv.
+func extractEnv(ctx context.Context, dataDir string, src fs.FS, log Logger) error {
+	if extracted(dataDir) {
+		return nil
 	}
-	rf.Write(rng)
 
-	return os.ReadFile("./tmp/pglite/bin/postgres.wasi")
+	extractMu.Lock()
+	defer extractMu.Unlock()
+	for _, dir := range []string{dataDir, filepath.Join(dataDir, "tmp")} {
+		if err := checkDir(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(dataDir, ".pglite-extract.lock"))
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	defer unlock()
+
+	// Another process may have extracted the tree while we waited.
+	if extracted(dataDir) {
+		return nil
+	}
+	log.Infof("extracting env to %s", dataDir)
+	return installEnv(ctx, dataDir, src)
+}
+
+// extracted reports whether dataDir holds a completed extraction and a
+// cluster.
+func extracted(dataDir string) bool {
+	for _, name := range []string{extractedMarker, clusterDir + "/PG_VERSION"} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// extractTarGz extracts the gzipped tar archive read from r into dir. The
+// archive may hold only directories and regular files, under root, a
+// slash-separated path relative to dir; any other entry, including a
+// symbolic or hard link or a name that would land outside root, fails the
+// extraction.
+func extractTarGz(r io.Reader, dir, root string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) || (name != root && !strings.HasPrefix(name, root+"/")) {
+			return fmt.Errorf("tar entry %q is outside %s", header.Name, root)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+			if err := extractFile(dest, tr); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported file type in tar: %c (%s)", header.Typeflag, header.Name)
+		}
+	}
+}
+
+// extractFile writes the contents read from r to a new file at dest.
+func extractFile(dest string, r io.Reader) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
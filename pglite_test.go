@@ -2,25 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/tetratelabs/wazero/sys"
 )
 
 var testPG *PGLite
 
 func TestMain(m *testing.M) {
-	// If running as subprocess for output capture, handle that
-	if os.Getenv("PGLITE_SUBPROCESS") == "1" {
-		runSubprocess()
-		return
-	}
-
 	ctx := context.Background()
+	instanceCreated, instanceLeaked = trackInstance, trackLeak
 
-	var err error
-	testPG, err = NewPGLite(ctx, os.Stdout, os.Stderr)
+	// The shared instance has a data directory of its own, as other tests
+	// start instances in the current directory.
+	dir, err := os.MkdirTemp("", "pglite-test-")
+	if err != nil {
+		panic(err)
+	}
+	testPG, err = NewPGLiteWithOptions(ctx, os.Stdout, os.Stderr, Options{DataDir: dir})
 	if err != nil {
 		panic("failed to initialize PGLite: " + err.Error())
 	}
@@ -28,85 +37,431 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 
 	testPG.Close()
+	os.RemoveAll(dir)
+	if err := checkClosed(); err != nil && code == 0 {
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	}
 	os.Exit(code)
 }
 
-// runSubprocess runs queries passed via PGLITE_QUERIES env var and exits.
-// Output goes to stderr (where PGLite writes query results).
-func runSubprocess() {
-	ctx := context.Background()
-	pg, err := NewPGLite(ctx, os.Stdout, os.Stderr)
+func TestShowClientEncoding(t *testing.T) {
+	output, err := testPG.QueryString("SHOW client_encoding;")
+	if err != nil {
+		t.Fatalf("QueryString: %v", err)
+	}
+	if !strings.Contains(output, "UTF8") {
+		t.Errorf("expected output to contain 'UTF8', got: %s", output)
+	}
+}
+
+func TestCreateAndCallFunction(t *testing.T) {
+	queries := "CREATE OR REPLACE FUNCTION test_func() RETURNS TEXT AS $$ BEGIN RETURN 'test'; END; $$ LANGUAGE plpgsql;\n" +
+		"SELECT test_func();"
+	output, err := testPG.QueryString(queries)
+	if err != nil {
+		t.Fatalf("QueryString: %v", err)
+	}
+	if !strings.Contains(output, "\n test\n") {
+		t.Errorf("expected output to contain the row 'test', got: %s", output)
+	}
+}
+
+func TestArithmeticFunction(t *testing.T) {
+	createSQL := `CREATE OR REPLACE FUNCTION addition (entier1 integer, entier2 integer) RETURNS integer LANGUAGE plpgsql IMMUTABLE AS 'DECLARE resultat integer; BEGIN resultat := entier1 + entier2; RETURN resultat; END';`
+	queries := createSQL + "\nSELECT addition(40,2);"
+	output, err := testPG.QueryString(queries)
+	if err != nil {
+		t.Fatalf("QueryString: %v", err)
+	}
+	if !strings.Contains(output, "42") {
+		t.Errorf("expected output to contain '42', got: %s", output)
+	}
+}
+
+func TestSelectNow(t *testing.T) {
+	output, err := testPG.QueryString("SELECT now(), current_database(), session_user, current_user;")
+	if err != nil {
+		t.Fatalf("QueryString: %v", err)
+	}
+	if !strings.Contains(output, "postgres") {
+		t.Errorf("expected output to contain 'postgres', got: %s", output)
+	}
+}
+
+func TestQueryContextTimeout(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = pg.QueryContext(ctx, "SELECT count(*) FROM generate_series(1, 1000000000);")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query was not interrupted, took %v", elapsed)
+	}
+}
+
+func TestQueryTooLarge(t *testing.T) {
+	sql := "SELECT '" + strings.Repeat("x", maxQuerySize) + "';"
+	err := testPG.Query(sql)
+	if !errors.Is(err, ErrQueryTooLarge) {
+		t.Fatalf("expected ErrQueryTooLarge, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(len(sql)+1)) {
+		t.Errorf("error does not report the query length: %v", err)
+	}
+
+	if _, err := testPG.QueryRows("SELECT 1;"); err != nil {
+		t.Errorf("instance unusable after rejected query: %v", err)
+	}
+}
+
+func TestNewPGLiteFromWASM(t *testing.T) {
+	dir := t.TempDir()
+	if err := ExtractEnv(dir); err != nil {
+		t.Fatalf("ExtractEnv: %v", err)
+	}
+	wasm, err := os.ReadFile(filepath.Join(dir, "tmp/pglite/bin/postgres.wasi"))
+	if err != nil {
+		t.Fatalf("read module: %v", err)
+	}
+
+	pg, err := NewPGLiteFromWASM(context.Background(), wasm, io.Discard, io.Discard, Options{DataDir: dir})
 	if err != nil {
-		os.Stderr.WriteString("INIT_ERROR: " + err.Error() + "\n")
-		os.Exit(1)
+		t.Fatalf("NewPGLiteFromWASM: %v", err)
+	}
+	defer pg.Close()
+
+	var n int
+	if err := pg.QueryRow("SELECT 1 + 1;").Scan(&n); err != nil || n != 2 {
+		t.Errorf("got %d, %v; want 2", n, err)
+	}
+}
+
+func TestRunQueriesFromReader(t *testing.T) {
+	script := "CREATE TABLE reader_test (id integer);\n\n" +
+		"INSERT INTO reader_test\nVALUES (1);\n\n\n" +
+		"INSERT INTO reader_test VALUES (2);"
+	if err := testPG.RunQueriesFromReader(iotest.OneByteReader(strings.NewReader(script))); err != nil {
+		t.Fatalf("RunQueriesFromReader: %v", err)
+	}
+
+	var n int
+	if err := testPG.QueryRow("SELECT count(*) FROM reader_test;").Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("count = %d, want 2", n)
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	pg.Close()
+	pg.Close()
+
+	if err := pg.Query("SELECT 1;"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Query: expected ErrClosed, got: %v", err)
+	}
+	if _, err := pg.Exec("SELECT 1;"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Exec: expected ErrClosed, got: %v", err)
+	}
+	if _, err := pg.QueryRows("SELECT 1;"); !errors.Is(err, ErrClosed) {
+		t.Errorf("QueryRows: expected ErrClosed, got: %v", err)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	var stderr strings.Builder
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, &stderr, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	var buf strings.Builder
+	if err := pg.QueryTo(&buf, "SELECT 'query to' AS v;"); err != nil {
+		t.Fatalf("QueryTo: %v", err)
+	}
+	if !strings.Contains(buf.String(), `v = "query to"`) {
+		t.Errorf("QueryTo output missing the row: %q", buf.String())
 	}
 
-	queries := os.Getenv("PGLITE_QUERIES")
-	for _, q := range strings.Split(queries, ";;") {
-		q = strings.TrimSpace(q)
-		if q == "" {
-			continue
+	stderr.Reset()
+	var redirected strings.Builder
+	pg.SetOutput(io.Discard, &redirected)
+	if err := pg.Query("SELECT 'redirected' AS v;"); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(redirected.String(), `v = "redirected"`) {
+		t.Errorf("redirected output missing the row: %q", redirected.String())
+	}
+	if strings.Contains(stderr.String(), "redirected") {
+		t.Errorf("output still went to the original writer: %q", stderr.String())
+	}
+}
+
+func TestOnQuery(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	type call struct {
+		sql string
+		err error
+	}
+	var calls []call
+	pg.OnQuery(func(sql string, d time.Duration, err error) {
+		if d <= 0 {
+			t.Errorf("%s: expected a positive duration, got %v", sql, d)
 		}
-		if err := pg.Query(q); err != nil {
-			os.Stderr.WriteString("QUERY_ERROR: " + err.Error() + "\n")
-			os.Exit(1)
+		calls = append(calls, call{sql, err})
+	})
+
+	pg.RunQueries("SELECT 1; SELECT * FROM missing;")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d: %v", len(calls), calls)
+	}
+	if calls[0].sql != "SELECT 1;" || calls[0].err != nil {
+		t.Errorf("unexpected first call: %+v", calls[0])
+	}
+	var qerr *QueryError
+	if !errors.As(calls[1].err, &qerr) {
+		t.Errorf("expected the hook to see the QueryError, got: %v", calls[1].err)
+	}
+
+	pg.OnQuery(nil)
+	if err := pg.Query("SELECT 2;"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("hook still called after removal: %v", calls)
+	}
+}
+
+func TestUserAndDatabase(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{DataDir: dir, User: "app_owner", Database: "myapp", ClientEncoding: "SQL_ASCII"}
+	for i := 0; i < 2; i++ {
+		pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, opts)
+		if err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		res, err := pg.QueryRows("SELECT current_database(), current_user, current_setting('client_encoding');")
+		pg.Close()
+		if err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		want := []string{"myapp", "app_owner", "SQL_ASCII"}
+		for j, w := range want {
+			if res.Rows[0][j] != w {
+				t.Errorf("start %d: column %d: got %q, want %q", i, j, res.Rows[0][j], w)
+			}
 		}
 	}
-	pg.Close()
-	os.Exit(0)
 }
 
-// runQueriesInSubprocess executes queries in a fresh subprocess and returns
-// the combined output. Queries are separated by ";;".
-func runQueriesInSubprocess(t *testing.T, queries string) string {
-	t.Helper()
+func TestExtractEnvConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	logs := make([]strings.Builder, 4)
+	errs := make(chan error, len(logs))
+	for i := range logs {
+		go func() {
+			errs <- extractEnv(context.Background(), dir, nil, NewLogger(&logs[i]))
+		}()
+	}
+	for range logs {
+		if err := <-errs; err != nil {
+			t.Fatalf("extractEnv: %v", err)
+		}
+	}
+
+	extractions := 0
+	for i := range logs {
+		if strings.Contains(logs[i].String(), "extracting env") {
+			extractions++
+		}
+	}
+	if extractions != 1 {
+		t.Errorf("expected exactly one extraction, got %d", extractions)
+	}
 
-	// Get the test binary path
-	exe, err := os.Executable()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
 	if err != nil {
-		t.Fatalf("os.Executable: %v", err)
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
+
+func TestMemoryLimitPages(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: t.TempDir(), MemoryLimitPages: 64})
+	if err == nil {
+		t.Fatal("expected a 4 MiB limit to be too small to start")
 	}
 
-	cmd := exec.Command(exe, "-test.run", "^$")
-	cmd.Env = append(os.Environ(),
-		"PGLITE_SUBPROCESS=1",
-		"PGLITE_QUERIES="+queries,
-	)
-	output, err := cmd.CombinedOutput()
+	// 512 MiB is enough to start but not to build a 600 MB string.
+	pg, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: t.TempDir(), MemoryLimitPages: 8192})
 	if err != nil {
-		t.Fatalf("subprocess failed: %v\noutput: %s", err, string(output))
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	err = pg.Query("SELECT length(repeat('x', 600000000));")
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "53200" {
+		t.Fatalf("expected an out of memory QueryError, got: %v", err)
+	}
+	if err := pg.Ping(ctx); err != nil {
+		t.Errorf("instance unusable after running out of memory: %v", err)
 	}
-	return string(output)
 }
 
-func TestShowClientEncoding(t *testing.T) {
-	output := runQueriesInSubprocess(t, "SHOW client_encoding;")
-	if !strings.Contains(output, "UTF8") {
-		t.Errorf("expected output to contain 'UTF8', got: %s", output)
+func TestNewPGLiteInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the interpreter is slow")
+	}
+	t.Chdir(t.TempDir())
+
+	pg, err := NewPGLiteInterpreter(context.Background(), io.Discard, io.Discard)
+	if err != nil {
+		t.Fatalf("NewPGLiteInterpreter: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
 	}
 }
 
-func TestCreateAndCallFunction(t *testing.T) {
-	queries := "CREATE OR REPLACE FUNCTION test_func() RETURNS TEXT AS $$ BEGIN RETURN 'test'; END; $$ LANGUAGE plpgsql;;;" +
-		"SELECT test_func();"
-	output := runQueriesInSubprocess(t, queries)
-	if !strings.Contains(output, "test") {
-		t.Errorf("expected output to contain 'test', got: %s", output)
+func TestCallFailed(t *testing.T) {
+	tests := []struct {
+		err   error
+		code  int
+		exit  bool
+		fault bool
+	}{
+		{sys.NewExitError(1), 1, true, true},
+		{sys.NewExitError(0), 0, true, false},
+		{sys.NewExitError(sys.ExitCodeDeadlineExceeded), int(sys.ExitCodeDeadlineExceeded), true, false},
+		{errors.New("wasm error: unreachable"), 0, false, true},
+	}
+	for _, tt := range tests {
+		p := &PGLite{}
+		p.callFailed(tt.err)
+		code, exit := p.LastExitCode()
+		if code != tt.code || exit != tt.exit || (p.fault != nil) != tt.fault {
+			t.Errorf("%v: got code %d, exited %v, fault %v", tt.err, code, exit, p.fault)
+		}
 	}
 }
 
-func TestArithmeticFunction(t *testing.T) {
-	createSQL := `CREATE OR REPLACE FUNCTION addition (entier1 integer, entier2 integer) RETURNS integer LANGUAGE plpgsql IMMUTABLE AS 'DECLARE resultat integer; BEGIN resultat := entier1 + entier2; RETURN resultat; END';`
-	queries := createSQL + ";;;" + "SELECT addition(40,2);"
-	output := runQueriesInSubprocess(t, queries)
-	if !strings.Contains(output, "42") {
-		t.Errorf("expected output to contain '42', got: %s", output)
+func TestLastExitCode(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	if _, exited := pg.LastExitCode(); exited {
+		t.Error("expected a running instance not to have exited")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pg.QueryContext(ctx, "SELECT 1;")
+	if code, exited := pg.LastExitCode(); !exited || uint32(code) != sys.ExitCodeContextCanceled {
+		t.Errorf("expected exit code %d, got %d, %v", sys.ExitCodeContextCanceled, code, exited)
+	}
+	if err := pg.Close(); err != nil {
+		t.Errorf("cancellation is not a crash, but Close returned: %v", err)
 	}
 }
 
-func TestSelectNow(t *testing.T) {
-	output := runQueriesInSubprocess(t, "SELECT now(), current_database(), session_user, current_user;")
-	if !strings.Contains(output, "postgres") {
-		t.Errorf("expected output to contain 'postgres', got: %s", output)
+func TestLastOutput(t *testing.T) {
+	if err := testPG.Query("SELECT * FROM last_output_missing;"); err == nil {
+		t.Fatal("expected an error")
+	}
+	out := testPG.LastOutput()
+	if !strings.Contains(out, `relation "last_output_missing" does not exist`) {
+		t.Errorf("error missing from last output:\n%s", out)
+	}
+	if len(out) > tailBufferSize {
+		t.Errorf("last output is %d bytes, limit %d", len(out), tailBufferSize)
+	}
+}
+
+func TestMultiStatementQuery(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := pg.QueryRow("SELECT count(*) FROM m;").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// Every statement runs.
+	if err := pg.Query("CREATE TABLE m (n int); INSERT INTO m VALUES (1); INSERT INTO m VALUES (2);"); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("rows after two inserts: %d", n)
+	}
+
+	// A failure stops the rest and rolls back the implicit transaction.
+	err = pg.Query("INSERT INTO m VALUES (3); SELECT * FROM missing; INSERT INTO m VALUES (4);")
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "42P01" {
+		t.Errorf("got %v, want the missing table's error", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("rows after a failed batch: %d, want 2", n)
+	}
+
+	// An explicit transaction commits before the failure.
+	if err := pg.Query("BEGIN; INSERT INTO m VALUES (3); COMMIT; SELECT * FROM missing;"); err == nil {
+		t.Error("expected an error")
+	}
+	if n := count(); n != 3 {
+		t.Errorf("rows after a committed transaction: %d, want 3", n)
+	}
+
+	// QueryRows returns the last result set, even an empty one.
+	res, err := pg.QueryRows("SELECT 1 AS a; SELECT 'x' AS b, 2 AS c;")
+	if err != nil || !slices.Equal(res.Columns, []string{"b", "c"}) || len(res.Rows) != 1 || !slices.Equal(res.Rows[0], []string{"x", "2"}) {
+		t.Errorf("QueryRows: got %+v, %v", res, err)
+	}
+	res, err = pg.QueryRows("SELECT n FROM m; SELECT n AS none FROM m WHERE false;")
+	if err != nil || !slices.Equal(res.Columns, []string{"none"}) || len(res.Rows) != 0 {
+		t.Errorf("QueryRows with an empty last result: got %+v, %v", res, err)
+	}
+
+	// QueryEach sees the rows of each.
+	var rows []string
+	err = pg.QueryEach("SELECT 1; SELECT 'two', 3;", func(row []string) error {
+		rows = append(rows, strings.Join(row, ","))
+		return nil
+	})
+	if err != nil || !slices.Equal(rows, []string{"1", "two,3"}) {
+		t.Errorf("QueryEach: got %q, %v", rows, err)
 	}
 }
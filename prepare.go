@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Stmt is a statement prepared on the server with Prepare.
+type Stmt struct {
+	pg      *PGLite
+	name    string
+	nparams int
+	closed  bool
+}
+
+// Prepare prepares sql as a named statement with PREPARE, so that it is
+// parsed and planned once and run many times. sql uses placeholders $1, $2,
+// ...; their types are inferred by the server.
+//
+// Prepared statements belong to the session, which ends when the backend
+// restarts: after a statement fails, on UseDatabase and on resuming from
+// IdleTimeout. The statement is prepared again in the new session, until
+// it is closed. If that fails, as when a table it uses was dropped or is
+// not in the database UseDatabase switched to, the failure goes to
+// Options.Logger and the statement is left unprepared, so that running it
+// fails with SQLSTATE 26000 (invalid_sql_statement_name).
+func (p *PGLite) Prepare(name, sql string) (*Stmt, error) {
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	prepare := "PREPARE " + quoteIdent(name) + " AS " + sql + ";"
+	if err := p.Query(prepare); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.prepared == nil {
+		p.prepared = make(map[string]string)
+	}
+	p.prepared[name] = prepare
+	p.mu.Unlock()
+	res, err := p.QueryRowsParams("SELECT cardinality(parameter_types) FROM pg_prepared_statements WHERE name = $1;", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) != 1 {
+		return nil, fmt.Errorf("prepare %s: statement not found after PREPARE", name)
+	}
+	n, err := strconv.Atoi(res.Rows[0][0])
+	if err != nil {
+		return nil, fmt.Errorf("prepare %s: %w", name, err)
+	}
+	return &Stmt{pg: p, name: name, nparams: n}, nil
+}
+
+// NumInput returns the number of placeholders in the statement.
+func (s *Stmt) NumInput() int {
+	return s.nparams
+}
+
+// Exec runs the statement with args, quoted as by QueryParams.
+func (s *Stmt) Exec(args ...any) error {
+	q, err := s.execute(args)
+	if err != nil {
+		return err
+	}
+	return s.pg.Query(q)
+}
+
+// Query runs the statement with args and returns its rows.
+func (s *Stmt) Query(args ...any) (*Result, error) {
+	q, err := s.execute(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.pg.QueryRows(q)
+}
+
+// execute returns the EXECUTE statement that runs s with args.
+func (s *Stmt) execute(args []any) (string, error) {
+	if s.closed {
+		return "", errors.New("statement is closed")
+	}
+	if len(args) != s.nparams {
+		return "", fmt.Errorf("statement %s takes %d arguments, got %d", s.name, s.nparams, len(args))
+	}
+	q := "EXECUTE " + quoteIdent(s.name)
+	if len(args) > 0 {
+		lits := make([]string, len(args))
+		for i, a := range args {
+			lit, err := literal(a)
+			if err != nil {
+				return "", fmt.Errorf("argument %d: %w", i+1, err)
+			}
+			lits[i] = lit
+		}
+		q += "(" + strings.Join(lits, ", ") + ")"
+	}
+	return q + ";", nil
+}
+
+// Close removes the statement from the session with DEALLOCATE. Closing a
+// closed statement does nothing.
+func (s *Stmt) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.pg.mu.Lock()
+	delete(s.pg.prepared, s.name)
+	s.pg.mu.Unlock()
+	return s.pg.Query("DEALLOCATE " + quoteIdent(s.name) + ";")
+}
+
+// reprepare prepares the statements made with Prepare again, in name
+// order, after a restart. One that fails is logged and dropped; as the
+// failure restarts the backend again, those prepared before it are kept
+// for that restart to prepare. The caller must hold p.mu.
+func (p *PGLite) reprepare() {
+	stmts := p.prepared
+	p.prepared = make(map[string]string, len(stmts))
+	names := make([]string, 0, len(stmts))
+	for name := range stmts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := p.query(p.ctx, stmts[name], nil, nil); err != nil {
+			p.log.Errorf("prepare %s again after restart: %v", name, err)
+			continue
+		}
+		p.prepared[name] = stmts[name]
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPrepare(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.Query("CREATE TABLE prep (id integer, name text);"); err != nil {
+		t.Fatal(err)
+	}
+	ins, err := pg.Prepare("ins", "INSERT INTO prep VALUES ($1, $2);")
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if ins.NumInput() != 2 {
+		t.Errorf("NumInput: got %d, want 2", ins.NumInput())
+	}
+	for i, name := range []string{"one", "it's two"} {
+		if err := ins.Exec(i+1, name); err != nil {
+			t.Fatalf("Exec: %v", err)
+		}
+	}
+	if err := ins.Exec(3); err == nil {
+		t.Error("expected an error for a missing argument")
+	}
+
+	sel, err := pg.Prepare("sel", "SELECT name FROM prep WHERE id = $1")
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	res, err := sel.Query(2)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "it's two" {
+		t.Errorf("unexpected rows: %q", res.Rows)
+	}
+
+	if err := ins.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := ins.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := ins.Exec(4, "four"); err == nil {
+		t.Error("expected an error after Close")
+	}
+	res, err = pg.QueryRows("SELECT name FROM pg_prepared_statements ORDER BY name;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "sel" {
+		t.Errorf("expected only sel to remain prepared, got %q", res.Rows)
+	}
+}
+
+func TestPrepareSurvivesRestart(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.RunQueries("CREATE TABLE prep_keep (id integer); INSERT INTO prep_keep VALUES (1); CREATE TABLE prep_gone (id integer);"); err != nil {
+		t.Fatal(err)
+	}
+	keep, err := pg.Prepare("keep", "SELECT id FROM prep_keep WHERE id = $1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := pg.Prepare("gone", "SELECT count(*) FROM prep_gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Query("CREATE TEMP TABLE scratch (x int);"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unrelated error restarts the backend, and the statements are
+	// prepared again.
+	if err := pg.Query("SELECT 1/0;"); err == nil {
+		t.Fatal("expected division by zero")
+	}
+	if res, err := keep.Query(1); err != nil || len(res.Rows) != 1 {
+		t.Errorf("keep after restart: %v, %v", res, err)
+	}
+	if _, err := gone.Query(); err != nil {
+		t.Errorf("gone after restart: %v", err)
+	}
+	if err := pg.Query("SELECT * FROM scratch;"); err == nil {
+		t.Error("expected the temporary table to be lost")
+	}
+
+	// One that cannot be prepared again is dropped; the rest are kept.
+	if err := pg.Query("DROP TABLE prep_gone;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Query("SELECT 1/0;"); err == nil {
+		t.Fatal("expected division by zero")
+	}
+	if res, err := keep.Query(1); err != nil || len(res.Rows) != 1 {
+		t.Errorf("keep after failed re-prepare: %v, %v", res, err)
+	}
+	var qerr *QueryError
+	if _, err := gone.Query(); !errors.As(err, &qerr) || qerr.Code != "26000" {
+		t.Errorf("gone: got %v, want SQLSTATE 26000", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readOnlyCopy runs start as Options.ReadOnly describes if opts.ReadOnly is
+// set: it first has start initialize the cluster in opts.DataDir if there
+// is none, then runs start again on a private copy of the data directory,
+// which is removed when the instance is closed.
+func readOnlyCopy(ctx context.Context, opts Options, start func(Options) (*PGLite, error)) (*PGLite, error) {
+	if !opts.ReadOnly {
+		return start(opts)
+	}
+	if opts.InMemory || opts.TempDir != "" {
+		return nil, fmt.Errorf("read-only: InMemory and TempDir: %w", ErrNotSupported)
+	}
+	if len(opts.Mounts) > 0 {
+		return nil, errors.New("read-only: Mounts are writable; use ReadOnlyMounts")
+	}
+
+	dataDir := opts.dataDir()
+	if _, err := os.Stat(filepath.Join(dataDir, clusterDir, "PG_VERSION")); opts.ResetOnStart || err != nil {
+		init := opts
+		init.ReadOnly = false
+		p, err := start(init)
+		if err != nil {
+			return nil, fmt.Errorf("read-only: initialize: %w", err)
+		}
+		if err := p.Shutdown(ctx); err != nil {
+			return nil, fmt.Errorf("read-only: initialize: %w", err)
+		}
+	}
+
+	dir, err := memoryTempDir()
+	if err != nil {
+		return nil, fmt.Errorf("read-only: %w", err)
+	}
+	if err := copyEnv(dataDir, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("read-only: copy data directory: %w", err)
+	}
+
+	opts.DataDir = dir
+	opts.ResetOnStart = false
+	p, err := start(opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	p.removeDir = dir
+	return p, nil
+}
+
+// copyEnv copies the installation tree and cluster in src to dst, leaving
+// out the lock and socket files of any backend running there.
+func copyEnv(src, dst string) error {
+	if err := copyFile(filepath.Join(src, extractedMarker), filepath.Join(dst, extractedMarker), 0o644); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	root := filepath.Join(src, "tmp/pglite")
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == "postmaster.pid" || strings.HasPrefix(d.Name(), ".s.PGSQL.") {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dest, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, dest)
+		case info.Mode().IsRegular():
+			return copyFile(path, dest, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyFile copies the regular file src to dst, creating dst with mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyDataDir(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The first start initializes the cluster in dir.
+	pg, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	pg.Close()
+
+	pg, err = NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	if err := pg.RunQueries("CREATE TABLE replica (n integer); INSERT INTO replica VALUES (1), (2);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	type stamp struct {
+		size int64
+		mod  time.Time
+	}
+	files := func() map[string]stamp {
+		m := map[string]stamp{}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			m[path] = stamp{info.Size(), info.ModTime()}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	before := files()
+
+	pg, err = NewPGLiteWithOptions(ctx, io.Discard, io.Discard, Options{DataDir: dir, ReadOnly: true, CheckpointOnClose: true})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	copyDir := pg.dataDir
+
+	var n int
+	if err := pg.QueryRow("SELECT count(*) FROM replica;").Scan(&n); err != nil || n != 2 {
+		t.Errorf("count: got %d, %v", n, err)
+	}
+	if err := pg.Query("INSERT INTO replica VALUES (3);"); err != nil {
+		t.Errorf("insert into the copy: %v", err)
+	}
+	if err := pg.Query("COPY replica TO '/tmp/replica.csv';"); err != nil {
+		t.Errorf("COPY TO the copy: %v", err)
+	}
+	if err := pg.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(copyDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed on Close, got: %v", copyDir, err)
+	}
+	after := files()
+	if len(after) == 0 {
+		t.Fatal("no files in the data directory")
+	}
+	for path, s := range before {
+		if after[path] != s {
+			t.Errorf("%s changed", path)
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			t.Errorf("%s created", path)
+		}
+	}
+
+	for _, opts := range []Options{
+		{DataDir: dir, ReadOnly: true, Mounts: map[string]string{t.TempDir(): "/data"}},
+		{ReadOnly: true, InMemory: true},
+	} {
+		if _, err := NewPGLiteWithOptions(ctx, io.Discard, io.Discard, opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestReadOnlyQueries(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), ReadOnlyQueries: true})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	// The second attempt runs in the backend restarted after the first.
+	for i := 0; i < 2; i++ {
+		err := pg.Query("CREATE TABLE guarded (n integer);")
+		var qerr *QueryError
+		if !errors.As(err, &qerr) || qerr.Code != "25006" {
+			t.Errorf("attempt %d: expected read_only_sql_transaction, got: %v", i, err)
+		}
+	}
+	var n int
+	if err := pg.QueryRow("SELECT count(*) FROM pg_class WHERE relname = 'guarded';").Scan(&n); err != nil || n != 0 {
+		t.Errorf("SELECT: got %d, %v", n, err)
+	}
+}
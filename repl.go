@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// REPL reads statements from in and runs each in turn, as the pglite
+// command does with its standard input, until in is exhausted. Statements
+// are split as RunQueries splits them, so one may span several lines. Their
+// output goes to the instance's writers; an error is written to errw and
+// the loop carries on with the next statement.
+//
+// REPL returns nil at the end of in, or the error if reading fails or the
+// instance is closed.
+//
+// This package is a command, package main, so other programs cannot import
+// REPL; it is the loop the pglite command runs, kept separate so that it
+// can be moved with the rest of the API once that lives in a package of
+// its own.
+func (p *PGLite) REPL(in io.Reader, errw io.Writer) error {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(nil, maxStatementSize)
+	sc.Split(scanStatements)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		if err := p.Query(sc.Text()); err != nil {
+			if errors.Is(err, ErrClosed) {
+				return err
+			}
+			fmt.Fprintln(errw, err)
+		}
+	}
+	return sc.Err()
+}
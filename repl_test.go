@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestREPL(t *testing.T) {
+	in := strings.NewReader("CREATE TABLE repl_t (n integer);\n" +
+		"SELEC 1;\n" +
+		"INSERT INTO repl_t VALUES (1);\n" +
+		"INSERT INTO repl_t\n  VALUES (2); -- trailing comment\n" +
+		"INSERT INTO repl_t VALUES (3)") // no final semicolon
+	var errw bytes.Buffer
+	if err := testPG.REPL(in, &errw); err != nil {
+		t.Fatalf("REPL: %v", err)
+	}
+	if !strings.Contains(errw.String(), "syntax error") || strings.Count(errw.String(), "\n") != 1 {
+		t.Errorf("expected one syntax error, got %q", errw.String())
+	}
+
+	defer testPG.Query("DROP TABLE repl_t;")
+
+	var n int
+	if err := testPG.QueryRow("SELECT count(*) FROM repl_t;").Scan(&n); err != nil || n != 3 {
+		t.Errorf("count: got %d, %v", n, err)
+	}
+}
+
+func TestREPLInputEnds(t *testing.T) {
+	if err := testPG.REPL(strings.NewReader(""), io.Discard); err != nil {
+		t.Errorf("REPL at end of input: %v", err)
+	}
+
+	readErr := errors.New("read failed")
+	in := io.MultiReader(strings.NewReader("SELECT 1;\n"), iotest.ErrReader(readErr))
+	if err := testPG.REPL(in, io.Discard); !errors.Is(err, readErr) {
+		t.Errorf("REPL with failing input: got %v, want %v", err, readErr)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result holds the rows returned by a query.
+type Result struct {
+	Columns []string
+	Types   []uint32 // type OID of each column
+	Rows    [][]string
+
+	nulls [][]bool
+}
+
+// IsNull reports whether the value at the given row and column is NULL.
+// NULL values appear as empty strings in Rows.
+func (r *Result) IsNull(row, col int) bool {
+	return r.nulls[row][col]
+}
+
+// QueryRows executes a SQL statement and returns its rows, rather than
+// printing them to stderr as Query does. If sql holds several statements,
+// they run as for Query, and the rows are those of the last statement that
+// returns any columns; use QueryString to see the rows of each.
+func (p *PGLite) QueryRows(sql string) (*Result, error) {
+	return p.queryRows(p.ctx, sql)
+}
+
+func (p *PGLite) queryRows(ctx context.Context, sql string) (*Result, error) {
+	var res *Result
+	err := p.retry.retry(ctx, sql, func() error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		res = &Result{}
+		rp := &rowParser{onRow: func(row []string, nulls []bool) error {
+			res.Rows = append(res.Rows, row)
+			res.nulls = append(res.nulls, nulls)
+			return nil
+		}}
+		rp.onDescribe = func() {
+			// A later statement's rows replace those of the one before.
+			res.Rows, res.nulls = nil, nil
+		}
+		if err := p.query(ctx, sql, nil, rp); err != nil {
+			return err
+		}
+		res.Columns, res.Types = rp.columns, rp.types
+		p.stats.Rows += int64(len(res.Rows))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// rowHandler receives the rows of a query from the messages of the
+// backend's reply.
+type rowHandler interface {
+	describe(body []byte) error // a RowDescription message
+	dataRow(body []byte) error  // a DataRow message
+}
+
+// tagHandler is implemented by a rowHandler that also wants the command
+// tag of each statement, such as "COPY 5", from its CommandComplete
+// message.
+type tagHandler interface {
+	commandComplete(tag string)
+}
+
+// commandTag is the rowHandler of a statement whose rows are not wanted,
+// which keeps the command tag of the last statement to complete.
+type commandTag struct {
+	tag string
+}
+
+func (c *commandTag) describe([]byte) error      { return nil }
+func (c *commandTag) dataRow([]byte) error       { return nil }
+func (c *commandTag) commandComplete(tag string) { c.tag = tag }
+
+// rowParser decodes rows in text format, calling onRow for each. Each
+// RowDescription replaces the columns of the one before, and calls
+// onDescribe if it is set. Once onRow fails,
+// it is not called again and the error is kept in err, so that the rest
+// of the reply is still read.
+type rowParser struct {
+	columns    []string
+	types      []uint32
+	sizes      []int16 // type length, -1 for variable length
+	typmods    []int32
+	onRow      func(row []string, nulls []bool) error
+	onDescribe func()
+	err        error
+}
+
+func (rp *rowParser) describe(body []byte) error {
+	rp.columns, rp.types, rp.sizes, rp.typmods = nil, nil, nil, nil
+	if len(body) < 2 {
+		return errors.New("parse result: malformed row description")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	for i := 0; i < n; i++ {
+		end := bytes.IndexByte(body, 0)
+		if end < 0 || len(body) < end+1+18 {
+			return errors.New("parse result: malformed row description")
+		}
+		// Table OID (4) and column number (2) precede the type OID, type
+		// length (2), type modifier (4) and format code (2).
+		f := body[end+1:]
+		rp.columns = append(rp.columns, string(body[:end]))
+		rp.types = append(rp.types, binary.BigEndian.Uint32(f[6:]))
+		rp.sizes = append(rp.sizes, int16(binary.BigEndian.Uint16(f[10:])))
+		rp.typmods = append(rp.typmods, int32(binary.BigEndian.Uint32(f[12:])))
+		body = f[18:]
+	}
+	if rp.onDescribe != nil {
+		rp.onDescribe()
+	}
+	return nil
+}
+
+func (rp *rowParser) dataRow(body []byte) error {
+	if rp.err != nil {
+		return nil
+	}
+	if len(body) < 2 || int(binary.BigEndian.Uint16(body)) != len(rp.columns) {
+		return errors.New("parse result: malformed data row")
+	}
+	body = body[2:]
+	row := make([]string, len(rp.columns))
+	nulls := make([]bool, len(rp.columns))
+	for i := range row {
+		if len(body) < 4 {
+			return errors.New("parse result: malformed data row")
+		}
+		n := int32(binary.BigEndian.Uint32(body))
+		body = body[4:]
+		if n < 0 {
+			nulls[i] = true
+			continue
+		}
+		if len(body) < int(n) {
+			return errors.New("parse result: malformed data row")
+		}
+		row[i] = string(body[:n])
+		body = body[n:]
+	}
+	rp.err = rp.onRow(row, nulls)
+	return nil
+}
+
+// rowPrinter writes rows to w as the single-user backend prints them with
+// debugtup: a header block naming each column, then one block per row,
+// each block ending in a "----" line. NULL attributes are omitted from row
+// blocks.
+//
+//	 1: n	(typeid = 23, len = 4, typmod = -1, byval = t)
+//	----
+//	 1: n = "42"	(typeid = 23, len = 4, typmod = -1, byval = t)
+//	----
+type rowPrinter struct {
+	w  io.Writer
+	rp rowParser
+}
+
+func (pr *rowPrinter) describe(body []byte) error {
+	if err := pr.rp.describe(body); err != nil {
+		return err
+	}
+	pr.rp.onRow = pr.printRow
+	for i := range pr.rp.columns {
+		pr.printAttr(i, "")
+	}
+	io.WriteString(pr.w, "\t----\n")
+	return nil
+}
+
+func (pr *rowPrinter) dataRow(body []byte) error {
+	return pr.rp.dataRow(body)
+}
+
+func (pr *rowPrinter) printRow(row []string, nulls []bool) error {
+	for i, v := range row {
+		if !nulls[i] {
+			pr.printAttr(i, ` = "`+v+`"`)
+		}
+	}
+	io.WriteString(pr.w, "\t----\n")
+	return nil
+}
+
+func (pr *rowPrinter) printAttr(i int, value string) {
+	rp := &pr.rp
+	// Types of up to four bytes are passed by value in 32-bit WASM.
+	byval := 'f'
+	if s := rp.sizes[i]; s > 0 && s <= 4 {
+		byval = 't'
+	}
+	fmt.Fprintf(pr.w, "\t%2d: %s%s\t(typeid = %d, len = %d, typmod = %d, byval = %c)\n",
+		i+1, rp.columns[i], value, rp.types[i], rp.sizes[i], rp.typmods[i], byval)
+}
+
+// QueryEach executes a SQL statement and calls fn with each row as it is
+// read from the backend's reply, so a large result is never held in
+// memory. NULL values appear as empty strings. If sql holds several
+// statements, fn is called with the rows of each in turn.
+//
+// If fn returns an error, fn is not called again and QueryEach returns that
+// error once the statement finishes; the module cannot be interrupted
+// mid-statement, so the remaining rows are read and discarded.
+func (p *PGLite) QueryEach(sql string, fn func(row []string) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rp := &rowParser{onRow: func(row []string, _ []bool) error {
+		p.stats.Rows++
+		return fn(row)
+	}}
+	if err := p.query(p.ctx, sql, nil, rp); err != nil {
+		return err
+	}
+	return rp.err
+}
+
+// Ping checks that the instance is responsive by running a trivial query
+// under ctx.
+func (p *PGLite) Ping(ctx context.Context) error {
+	res, err := p.queryRows(ctx, "SELECT 1;")
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0][0] != "1" {
+		return fmt.Errorf("ping: unexpected result %q", res.Rows)
+	}
+	return nil
+}
+
+// waitReady pings the instance until it answers, waiting a little longer
+// after each failure. It gives up once ctx is done or the instance is
+// closed.
+func (p *PGLite) waitReady(ctx context.Context) error {
+	wait := time.Millisecond
+	for {
+		err := p.Ping(ctx)
+		if err == nil || errors.Is(err, ErrClosed) || ctx.Err() != nil {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("not ready: %w (last error: %v)", ctx.Err(), err)
+		case <-t.C:
+		}
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
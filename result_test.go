@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rowDescription builds the body of a RowDescription message.
+func rowDescription(columns []string, types []uint32, sizes []int16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(columns)))
+	for i, c := range columns {
+		b = append(b, c...)
+		b = append(b, 0, 0, 0, 0, 0, 0, 0)
+		b = binary.BigEndian.AppendUint32(b, types[i])
+		b = binary.BigEndian.AppendUint16(b, uint16(sizes[i]))
+		b = binary.BigEndian.AppendUint32(b, 0xffffffff)
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// dataRow builds the body of a DataRow message; a nil value is NULL.
+func dataRow(values ...*string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, v := range values {
+		if v == nil {
+			b = binary.BigEndian.AppendUint32(b, 0xffffffff)
+			continue
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(*v)))
+		b = append(b, *v...)
+	}
+	return b
+}
+
+func TestRowParser(t *testing.T) {
+	var rows [][]string
+	var nulls [][]bool
+	rp := &rowParser{onRow: func(row []string, n []bool) error {
+		rows = append(rows, row)
+		nulls = append(nulls, n)
+		return nil
+	}}
+	one, two, lines := "1", "2", "two\nlines"
+	for _, err := range []error{
+		rp.describe(rowDescription([]string{"id", "note"}, []uint32{23, 25}, []int16{4, -1})),
+		rp.dataRow(dataRow(&one, &lines)),
+		rp.dataRow(dataRow(&two, nil)),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"id", "note"}; !reflect.DeepEqual(rp.columns, want) {
+		t.Errorf("columns = %v, want %v", rp.columns, want)
+	}
+	if want := []uint32{23, 25}; !reflect.DeepEqual(rp.types, want) {
+		t.Errorf("types = %v, want %v", rp.types, want)
+	}
+	if want := [][]string{{"1", "two\nlines"}, {"2", ""}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	if want := [][]bool{{false, false}, {false, true}}; !reflect.DeepEqual(nulls, want) {
+		t.Errorf("nulls = %v, want %v", nulls, want)
+	}
+
+	if err := rp.dataRow(dataRow(&one)); err == nil {
+		t.Error("expected an error for a row with the wrong number of columns")
+	}
+
+	stop := errors.New("stop")
+	rp = &rowParser{onRow: func([]string, []bool) error { return stop }}
+	rp.describe(rowDescription([]string{"n"}, []uint32{23}, []int16{4}))
+	rp.dataRow(dataRow(&one))
+	if err := rp.dataRow(dataRow(&two)); err != nil || rp.err != stop {
+		t.Errorf("after onRow failed: got %v, kept %v", err, rp.err)
+	}
+}
+
+func TestRowPrinter(t *testing.T) {
+	var buf strings.Builder
+	pr := &rowPrinter{w: &buf}
+	one := "1"
+	pr.describe(rowDescription([]string{"n", "s"}, []uint32{23, 25}, []int16{4, -1}))
+	pr.dataRow(dataRow(&one, nil))
+
+	want := "\t 1: n\t(typeid = 23, len = 4, typmod = -1, byval = t)\n" +
+		"\t 2: s\t(typeid = 25, len = -1, typmod = -1, byval = f)\n" +
+		"\t----\n" +
+		"\t 1: n = \"1\"\t(typeid = 23, len = 4, typmod = -1, byval = t)\n" +
+		"\t----\n"
+	if buf.String() != want {
+		t.Errorf("got %q\nwant %q", buf.String(), want)
+	}
+}
+
+func TestQueryRows(t *testing.T) {
+	res, err := testPG.QueryRows("SELECT * FROM (VALUES (1, 'a'), (2, NULL), (3, 'c')) AS v(n, s);")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if want := []string{"n", "s"}; !reflect.DeepEqual(res.Columns, want) {
+		t.Errorf("columns = %v, want %v", res.Columns, want)
+	}
+	if len(res.Rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(res.Rows))
+	}
+	if !res.IsNull(1, 1) {
+		t.Errorf("expected row 1 column s to be NULL")
+	}
+	if res.Rows[2][1] != "c" {
+		t.Errorf("rows[2][1] = %q, want %q", res.Rows[2][1], "c")
+	}
+}
+
+func TestQueryRowsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := testPG.QueryRows(fmt.Sprintf("SELECT %d;", i))
+			if err != nil {
+				t.Errorf("QueryRows: %v", err)
+				return
+			}
+			if len(res.Rows) != 1 || res.Rows[0][0] != strconv.Itoa(i) {
+				t.Errorf("query %d: got %v", i, res.Rows)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPing(t *testing.T) {
+	if err := testPG.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	pg.Close()
+	if err := pg.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Ping after Close, got: %v", err)
+	}
+}
+
+func TestQueryEach(t *testing.T) {
+	var sum int
+	err := testPG.QueryEach("SELECT n, n::text || 'x' FROM generate_series(1, 1000) AS n;", func(row []string) error {
+		n, err := strconv.Atoi(row[0])
+		if err != nil {
+			return err
+		}
+		if row[1] != row[0]+"x" {
+			return fmt.Errorf("row %q", row)
+		}
+		sum += n
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("QueryEach: %v", err)
+	}
+	if sum != 500500 {
+		t.Errorf("sum = %d, want 500500", sum)
+	}
+
+	stop := errors.New("stop")
+	var calls int
+	err = testPG.QueryEach("SELECT n FROM generate_series(1, 100) AS n;", func(row []string) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("QueryEach err = %v, want %v", err, stop)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times after stopping, want 3", calls)
+	}
+
+	if err := testPG.Ping(context.Background()); err != nil {
+		t.Errorf("Ping after early stop: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy makes Query, QueryContext and QueryRows retry a statement
+// that failed for a transient reason, as reported by IsTransient. Only
+// statements judged idempotent are retried; SQL errors such as syntax or
+// constraint violations never are. The zero policy does not retry.
+type RetryPolicy struct {
+	// Attempts is the most times a statement is run, including the first.
+	// Values below 2 disable retries.
+	Attempts int
+
+	// Backoff is the wait before the first retry. It doubles for each
+	// further retry, up to MaxBackoff if that is not zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Idempotent reports whether sql is safe to run again. If nil, only
+	// read-only statements are retried: SELECT, VALUES, TABLE, SHOW and
+	// EXPLAIN without ANALYZE, and WITH queries that modify nothing. A
+	// SELECT that calls a function with side effects, such as nextval, is
+	// still judged read-only; supply Idempotent to be stricter.
+	Idempotent func(sql string) bool
+}
+
+// IsTransient reports whether err is a failure that may not recur if the
+// statement is run again.
+//
+// The one such failure is PostgreSQL running out of memory (SQLSTATE
+// 53200). When wazero refuses to grow the module's linear memory, because
+// of MemoryLimitPages or the host, memory.grow returns -1 to the module
+// rather than failing on the host side, so PostgreSQL's allocation fails
+// and it reports "out of memory", releases the statement's memory and
+// carries on. Errors from the runtime itself, such as a trap or the module
+// exiting, leave the instance closed or unusable and are not transient;
+// nor are cancellation, ErrClosed or ErrQueryTooLarge.
+func IsTransient(err error) bool {
+	var qerr *QueryError
+	return errors.As(err, &qerr) && qerr.Code == "53200"
+}
+
+// retry runs fn, then runs it again under the policy while it fails with a
+// transient error and sql is idempotent. It returns the last error, or
+// ctx.Err() if ctx is done while waiting to retry.
+func (rp RetryPolicy) retry(ctx context.Context, sql string, fn func() error) error {
+	err := fn()
+	if rp.Attempts < 2 || !IsTransient(err) {
+		return err
+	}
+	idempotent := rp.Idempotent
+	if idempotent == nil {
+		idempotent = readOnly
+	}
+	if !idempotent(sql) {
+		return err
+	}
+
+	wait := rp.Backoff
+	for attempt := 1; attempt < rp.Attempts && IsTransient(err); attempt++ {
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+			wait *= 2
+			if rp.MaxBackoff > 0 && wait > rp.MaxBackoff {
+				wait = rp.MaxBackoff
+			}
+		}
+		err = fn()
+	}
+	return err
+}
+
+// readOnly reports whether every statement in sql only reads, judged by
+// its words.
+func readOnly(sql string) bool {
+	stmts := splitStatements(sql)
+	if len(stmts) == 0 {
+		return false
+	}
+	for _, stmt := range stmts {
+		words := sqlWords(stmt)
+		if len(words) == 0 {
+			return false
+		}
+		switch words[0] {
+		case "SELECT", "VALUES", "TABLE", "SHOW", "WITH", "EXPLAIN":
+		default:
+			return false
+		}
+		for _, w := range words {
+			switch w {
+			case "INTO", "INSERT", "UPDATE", "DELETE", "MERGE", "ANALYZE", "ANALYSE":
+				return false
+			}
+		}
+	}
+	return true
+}
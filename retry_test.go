@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/sys"
+)
+
+func TestIsTransient(t *testing.T) {
+	oom := &QueryError{Severity: "ERROR", Code: "53200", Message: "out of memory"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"out of memory", oom, true},
+		{"wrapped out of memory", fmt.Errorf("set work_mem: %w", oom), true},
+		{"batch out of memory", &BatchError{Index: 2, Err: oom}, true},
+		{"undefined table", &QueryError{Severity: "ERROR", Code: "42P01"}, false},
+		{"unique violation", &QueryError{Severity: "ERROR", Code: "23505"}, false},
+		{"disk full", &QueryError{Severity: "ERROR", Code: "53100"}, false},
+		{"fatal without code", &QueryError{Severity: "FATAL", Message: "out of memory"}, false},
+		{"plain text", errors.New("out of memory"), false},
+		{"closed", ErrClosed, false},
+		{"too large", ErrQueryTooLarge, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"module exit", sys.NewExitError(1), false},
+		{"context exit", sys.NewExitError(sys.ExitCodeDeadlineExceeded), false},
+		{"trap", errors.New("wasm error: out of bounds memory access"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransient = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT 1;", true},
+		{"  select * from t where s = 'insert';", true},
+		{"VALUES (1), (2);", true},
+		{"TABLE t;", true},
+		{"SHOW work_mem;", true},
+		{"EXPLAIN SELECT * FROM t;", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x;", true},
+		{`SELECT "update" FROM t;`, true},
+		{"SELECT 1; SELECT 2;", true},
+		{"", false},
+		{"-- nothing", false},
+		{"INSERT INTO t VALUES (1);", false},
+		{"UPDATE t SET n = 1;", false},
+		{"SELECT * INTO t2 FROM t;", false},
+		{"SELECT * FROM t FOR UPDATE;", false},
+		{"EXPLAIN ANALYZE DELETE FROM t;", false},
+		{"EXPLAIN ANALYZE SELECT 1;", false},
+		{"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d;", false},
+		{"SELECT 1; DROP TABLE t;", false},
+		{"CREATE TABLE t (n int);", false},
+		{"SET work_mem = '64MB';", false},
+	}
+	for _, tt := range tests {
+		if got := readOnly(tt.sql); got != tt.want {
+			t.Errorf("readOnly(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	oom := &QueryError{Severity: "ERROR", Code: "53200", Message: "out of memory"}
+	syntax := &QueryError{Severity: "ERROR", Code: "42601", Message: "syntax error"}
+	ctx := context.Background()
+
+	// failing returns fn, which fails with errs in turn and then succeeds,
+	// and a pointer to the number of calls.
+	failing := func(errs ...error) (func() error, *int) {
+		calls := new(int)
+		return func() error {
+			*calls++
+			if *calls <= len(errs) {
+				return errs[*calls-1]
+			}
+			return nil
+		}, calls
+	}
+
+	t.Run("zero policy", func(t *testing.T) {
+		fn, calls := failing(oom)
+		if err := (RetryPolicy{}).retry(ctx, "SELECT 1;", fn); err != oom || *calls != 1 {
+			t.Errorf("got %v after %d calls, want %v after 1", err, *calls, oom)
+		}
+	})
+
+	t.Run("recovers", func(t *testing.T) {
+		fn, calls := failing(oom, oom)
+		if err := (RetryPolicy{Attempts: 3}).retry(ctx, "SELECT 1;", fn); err != nil || *calls != 3 {
+			t.Errorf("got %v after %d calls, want success after 3", err, *calls)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		fn, calls := failing(oom, oom, oom, oom)
+		if err := (RetryPolicy{Attempts: 3}).retry(ctx, "SELECT 1;", fn); err != oom || *calls != 3 {
+			t.Errorf("got %v after %d calls, want %v after 3", err, *calls, oom)
+		}
+	})
+
+	t.Run("SQL error", func(t *testing.T) {
+		fn, calls := failing(syntax)
+		if err := (RetryPolicy{Attempts: 3}).retry(ctx, "SELEC 1;", fn); err != syntax || *calls != 1 {
+			t.Errorf("got %v after %d calls, want %v after 1", err, *calls, syntax)
+		}
+	})
+
+	t.Run("SQL error on retry", func(t *testing.T) {
+		fn, calls := failing(oom, syntax, oom)
+		if err := (RetryPolicy{Attempts: 5}).retry(ctx, "SELECT 1;", fn); err != syntax || *calls != 2 {
+			t.Errorf("got %v after %d calls, want %v after 2", err, *calls, syntax)
+		}
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		fn, calls := failing(oom)
+		if err := (RetryPolicy{Attempts: 3}).retry(ctx, "INSERT INTO t VALUES (1);", fn); err != oom || *calls != 1 {
+			t.Errorf("got %v after %d calls, want %v after 1", err, *calls, oom)
+		}
+	})
+
+	t.Run("custom idempotent", func(t *testing.T) {
+		fn, calls := failing(oom)
+		rp := RetryPolicy{Attempts: 3, Idempotent: func(string) bool { return true }}
+		if err := rp.retry(ctx, "INSERT INTO t VALUES (1);", fn); err != nil || *calls != 2 {
+			t.Errorf("got %v after %d calls, want success after 2", err, *calls)
+		}
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		fn, _ := failing(oom, oom, oom)
+		rp := RetryPolicy{Attempts: 4, Backoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}
+		start := time.Now()
+		if err := rp.retry(ctx, "SELECT 1;", fn); err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+		// Waits of 10ms, then 15ms twice.
+		if d := time.Since(start); d < 40*time.Millisecond {
+			t.Errorf("retries took %v, want at least 40ms", d)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		fn, calls := failing(oom, oom)
+		rp := RetryPolicy{Attempts: 3, Backoff: time.Hour}
+		if err := rp.retry(ctx, "SELECT 1;", fn); !errors.Is(err, context.Canceled) || *calls != 1 {
+			t.Errorf("got %v after %d calls, want %v after 1", err, *calls, context.Canceled)
+		}
+	})
+}
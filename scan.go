@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Row is the result of QueryRow.
+type Row struct {
+	res *Result
+	err error
+}
+
+// QueryRow executes a query that is expected to return at most one row.
+// Errors are deferred until Scan is called.
+func (p *PGLite) QueryRow(sql string) *Row {
+	res, err := p.QueryRows(sql)
+	return &Row{res: res, err: err}
+}
+
+// Scan copies the columns of the first row into dest. Supported
+// destinations are *string, *int, *int64, *float64, *bool, *time.Time,
+// *[]byte, which takes bytea as DecodeBytea decodes it and any other type
+// as its text, and sql.Scanner implementations. If the query returned no
+// rows, Scan returns sql.ErrNoRows.
+func (r *Row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.res.Rows) == 0 {
+		return sql.ErrNoRows
+	}
+	if len(dest) != len(r.res.Columns) {
+		return fmt.Errorf("scan: expected %d destinations, got %d", len(r.res.Columns), len(dest))
+	}
+	for i, d := range dest {
+		if err := scanValue(d, r.res.Rows[0][i], r.res.IsNull(0, i), r.res.Types[i]); err != nil {
+			return fmt.Errorf("scan column %q: %w", r.res.Columns[i], err)
+		}
+	}
+	return nil
+}
+
+// Layouts of the date and time types in PostgreSQL's ISO output. Fractional
+// seconds are accepted by time.Parse without being in the layout.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05-07:00:00",
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// scanValue converts the text form of a value of type typ into dest.
+func scanValue(dest any, src string, null bool, typ uint32) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		if null {
+			return s.Scan(nil)
+		}
+		return s.Scan(src)
+	}
+	if null {
+		return fmt.Errorf("cannot scan NULL into %T", dest)
+	}
+
+	var err error
+	switch d := dest.(type) {
+	case *string:
+		*d = src
+	case *int:
+		*d, err = strconv.Atoi(src)
+	case *int64:
+		*d, err = strconv.ParseInt(src, 10, 64)
+	case *float64:
+		*d, err = strconv.ParseFloat(src, 64)
+	case *bool:
+		*d, err = parseBool(src)
+	case *time.Time:
+		*d, err = parseTime(src)
+	case *[]byte:
+		if typ == byteaOID {
+			*d, err = DecodeBytea(src)
+		} else {
+			*d = []byte(src)
+		}
+	default:
+		return fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return err
+}
+
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "t", "true":
+		return true, nil
+	case "f", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScanValue(t *testing.T) {
+	var s string
+	var n int64
+	var f float64
+	var b bool
+	var ts time.Time
+	var ns sql.NullString
+
+	for _, tc := range []struct {
+		dest any
+		src  string
+		null bool
+	}{
+		{&s, "hello", false},
+		{&n, "-42", false},
+		{&f, "3.5", false},
+		{&b, "t", false},
+		{&ts, "2024-10-17 12:30:00.25+02", false},
+		{&ns, "", true},
+	} {
+		if err := scanValue(tc.dest, tc.src, tc.null, 0); err != nil {
+			t.Errorf("scanValue(%T, %q): %v", tc.dest, tc.src, err)
+		}
+	}
+	if s != "hello" || n != -42 || f != 3.5 || !b || ns.Valid {
+		t.Errorf("unexpected values: %q %d %v %v %v", s, n, f, b, ns)
+	}
+	want := time.Date(2024, 10, 17, 10, 30, 0, 250e6, time.UTC)
+	if !ts.Equal(want) {
+		t.Errorf("time = %v, want %v", ts, want)
+	}
+
+	if err := scanValue(&s, "", true, 0); err == nil {
+		t.Error("expected error scanning NULL into *string")
+	}
+	if err := scanValue(&n, "abc", false, 0); err == nil {
+		t.Error("expected error scanning non-integer into *int64")
+	}
+}
+
+func TestQueryRowScan(t *testing.T) {
+	var n int
+	var name string
+	if err := testPG.QueryRow("SELECT 7, 'seven';").Scan(&n, &name); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if n != 7 || name != "seven" {
+		t.Errorf("got %d %q", n, name)
+	}
+
+	err := testPG.QueryRow("SELECT 1 WHERE false;").Scan(&n)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got: %v", err)
+	}
+
+	if err := testPG.QueryRow("SELECT 1, 2;").Scan(&n); err == nil {
+		t.Error("expected error for destination count mismatch")
+	}
+
+	// Only bytea is decoded; other types are scanned as their text.
+	var data, text []byte
+	if err := testPG.QueryRow(`SELECT '\x00ff'::bytea, 'C:\dir';`).Scan(&data, &text); err != nil {
+		t.Fatalf("Scan []byte: %v", err)
+	}
+	if string(data) != "\x00\xff" || string(text) != `C:\dir` {
+		t.Errorf("got %q %q", data, text)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+// TableInfo identifies a table.
+type TableInfo struct {
+	Schema string
+	Name   string
+}
+
+// ColumnInfo describes a column of a table.
+type ColumnInfo struct {
+	Name     string
+	Type     string // as written in SQL, for example "character varying(20)"
+	Nullable bool
+	Default  string // default expression, or "" if there is none
+}
+
+const tablesQuery = `SELECT n.nspname, c.relname FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p')`
+
+// Tables returns the tables in the user's schemas, leaving out pg_catalog,
+// information_schema and the other pg_ schemas, ordered by schema and name.
+func (p *PGLite) Tables() ([]TableInfo, error) {
+	return p.tables(tablesQuery + `
+AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+ORDER BY 1, 2;`)
+}
+
+// AllTables is like Tables but includes the system catalogs.
+func (p *PGLite) AllTables() ([]TableInfo, error) {
+	return p.tables(tablesQuery + "\nORDER BY 1, 2;")
+}
+
+func (p *PGLite) tables(query string) ([]TableInfo, error) {
+	res, err := p.QueryRows(query)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]TableInfo, len(res.Rows))
+	for i, row := range res.Rows {
+		tables[i] = TableInfo{Schema: row[0], Name: row[1]}
+	}
+	return tables, nil
+}
+
+// Columns returns the columns of table in order. table is resolved as in
+// SQL, so it may be schema-qualified and is found through search_path
+// otherwise.
+func (p *PGLite) Columns(table string) ([]ColumnInfo, error) {
+	res, err := p.QueryRowsParams(`SELECT a.attname, format_type(a.atttypid, a.atttypmod),
+NOT a.attnotnull, coalesce(pg_get_expr(d.adbin, d.adrelid), '')
+FROM pg_attribute a
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum;`, table)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]ColumnInfo, len(res.Rows))
+	for i, row := range res.Rows {
+		cols[i] = ColumnInfo{Name: row[0], Type: row[1], Nullable: row[2] == "t", Default: row[3]}
+	}
+	return cols, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestSchemaIntrospection(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	err = pg.RunQueries(`
+CREATE SCHEMA inv;
+CREATE TABLE inv.items (id serial PRIMARY KEY, name varchar(20) NOT NULL, qty integer DEFAULT 0);
+CREATE TABLE notes (body text);
+`)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	tables, err := pg.Tables()
+	if err != nil {
+		t.Fatalf("Tables: %v", err)
+	}
+	want := []TableInfo{{"inv", "items"}, {"public", "notes"}}
+	if !slices.Equal(tables, want) {
+		t.Errorf("Tables: got %v, want %v", tables, want)
+	}
+
+	all, err := pg.AllTables()
+	if err != nil {
+		t.Fatalf("AllTables: %v", err)
+	}
+	if !slices.Contains(all, TableInfo{"pg_catalog", "pg_class"}) {
+		t.Error("AllTables: expected pg_catalog.pg_class")
+	}
+
+	cols, err := pg.Columns("inv.items")
+	if err != nil {
+		t.Fatalf("Columns: %v", err)
+	}
+	wantCols := []ColumnInfo{
+		{Name: "id", Type: "integer", Nullable: false, Default: "nextval('inv.items_id_seq'::regclass)"},
+		{Name: "name", Type: "character varying(20)", Nullable: false},
+		{Name: "qty", Type: "integer", Nullable: true, Default: "0"},
+	}
+	if !slices.Equal(cols, wantCols) {
+		t.Errorf("Columns: got %+v, want %+v", cols, wantCols)
+	}
+
+	if _, err := pg.Columns("missing"); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
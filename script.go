@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ScriptError reports the statement that stopped a script.
+type ScriptError struct {
+	File      string // the script's path, or "" for RunQueries
+	Index     int    // 1-based position of the statement in the script
+	Line      int    // 1-based line the statement starts on, past any comments
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	where := "line " + strconv.Itoa(e.Line)
+	if e.File != "" {
+		where = e.File + ":" + strconv.Itoa(e.Line)
+	}
+	return fmt.Sprintf("%s: statement %d: %v; statement: %s", where, e.Index, e.Err, preview(e.Statement))
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// RunFile runs the SQL script at path, splitting it into statements as
+// RunQueries does and running each in turn. It stops at the first
+// statement that fails and returns a *ScriptError locating it. The file is
+// read as it goes, so it may be large.
+func (p *PGLite) RunFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := newLineScanner(f)
+	for index := 1; sc.Scan(); {
+		stmt := sc.Text()
+		if stmt == "" {
+			continue
+		}
+		if err := p.Query(stmt); err != nil {
+			return &ScriptError{File: path, Index: index, Line: sc.line, Statement: stmt, Err: err}
+		}
+		index++
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// previewSize is the most characters of a statement an error quotes.
+const previewSize = 80
+
+// preview returns the start of sql for an error message, on one line.
+func preview(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if r := []rune(s); len(r) > previewSize {
+		s = strings.TrimRight(string(r[:previewSize]), " ") + "..."
+	}
+	return s
+}
+
+// lineScanner splits SQL into statements as scanStatements does and
+// tracks the line each starts on.
+type lineScanner struct {
+	*bufio.Scanner
+	line int // line the last statement starts on
+	next int // line the unread input starts on
+}
+
+func newLineScanner(r io.Reader) *lineScanner {
+	s := &lineScanner{Scanner: bufio.NewScanner(r), next: 1}
+	s.Buffer(nil, maxStatementSize)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := scanStatements(data, atEOF)
+		if advance > 0 {
+			// The token is trimmed, so it starts at its first occurrence.
+			// The statement proper starts after any leading comments.
+			start := 0
+			if len(token) > 0 {
+				start = bytes.Index(data[:advance], token) + skipComments(string(token))
+			}
+			s.line = s.next + bytes.Count(data[:start], []byte("\n"))
+			s.next += bytes.Count(data[:advance], []byte("\n"))
+		}
+		return advance, token, err
+	})
+	return s
+}
+
+// skipComments returns the index in sql just past any leading white space
+// and comments.
+func skipComments(sql string) int {
+	i := 0
+	for i < len(sql) {
+		switch c := sql[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*"):
+			i = skipLiteral(sql, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLineScanner(t *testing.T) {
+	src := "-- seed data\nCREATE TABLE a (n int);\n\nINSERT INTO a\n  VALUES (1);   INSERT INTO a VALUES ('x\n;y');\n\n\nSELECT 1"
+	sc := newLineScanner(strings.NewReader(src))
+	var lines []int
+	for sc.Scan() {
+		if sc.Text() != "" {
+			lines = append(lines, sc.line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// Leading comments are skipped.
+	if want := []int{2, 4, 5, 9}; !slices.Equal(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.sql")
+	script := "CREATE TEMP TABLE run_file (n integer, s text);\n" +
+		"INSERT INTO run_file VALUES (1, 'a;b');\n" +
+		"\n" +
+		"INSERT INTO run_file\n" +
+		"  VALUES (2, $$two$$);\n"
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := testPG.RunFile(path); err != nil {
+		t.Fatalf("RunFile: %v", err)
+	}
+	var n int
+	if err := testPG.QueryRow("SELECT count(*) FROM run_file;").Scan(&n); err != nil || n != 2 {
+		t.Errorf("count: got %d, %v", n, err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.sql")
+	if err := os.WriteFile(bad, []byte("SELECT 1;\n\n-- next\nSELECT *\n  FROM missing_table;\nSELECT 2;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := testPG.RunFile(bad)
+	var serr *ScriptError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a *ScriptError, got %v", err)
+	}
+	if serr.File != bad || serr.Index != 2 || serr.Line != 4 {
+		t.Errorf("got file %s, statement %d, line %d; want %s, 2, 4", serr.File, serr.Index, serr.Line, bad)
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "42P01" {
+		t.Errorf("expected the undefined_table error to be wrapped, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), bad+":4: statement 2: ") {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	if err := testPG.RunFile(filepath.Join(t.TempDir(), "absent.sql")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestRunQueriesError(t *testing.T) {
+	script := "SELECT 1;\nSELECT 2; SELECT *\n  FROM missing_table\n  WHERE " + strings.Repeat("x = 1 AND ", 10) + "true;\nSELECT 3;"
+	err := testPG.RunQueries(script)
+	var serr *ScriptError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a *ScriptError, got %v", err)
+	}
+	if serr.File != "" || serr.Index != 3 || serr.Line != 2 {
+		t.Errorf("got file %q, statement %d, line %d; want \"\", 3, 2", serr.File, serr.Index, serr.Line)
+	}
+	var qerr *QueryError
+	if !errors.As(err, &qerr) || qerr.Code != "42P01" {
+		t.Errorf("expected the undefined_table error to be wrapped, got %v", err)
+	}
+	want := "line 2: statement 3: " + qerr.Error() + "; statement: SELECT * FROM missing_table WHERE x = 1 AND x = 1 AND x = 1 AND x = 1 AND x = 1..."
+	if err.Error() != want {
+		t.Errorf("got message\n%s\nwant\n%s", err, want)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT 1;":                 "SELECT 1;",
+		"SELECT a,\n\t  b\nFROM t;": "SELECT a, b FROM t;",
+		strings.Repeat("é", 100):    strings.Repeat("é", previewSize) + "...",
+	} {
+		if got := preview(sql); got != want {
+			t.Errorf("preview(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
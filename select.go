@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Select runs a query and stores its rows in dest, which must point to a
+// slice of structs or of pointers to structs. Each column is stored in the
+// field tagged `db:"column"`, or else in the field whose name in snake case
+// matches, so UserID takes user_id. Fields tagged `db:"-"` are skipped and
+// embedded structs are searched as if their fields were the outer
+// struct's. Columns with no field are ignored.
+//
+// Fields may be of the types Row.Scan supports, any string, bool, integer
+// or float kind, or pointers to those; a NULL leaves a pointer field nil
+// and is an error for any other field unless it implements sql.Scanner. A
+// column of a type with a decoder registered by RegisterType may go in any
+// field the decoded value can be stored in.
+func (p *PGLite) Select(dest any, sql string) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("select: dest must be a pointer to a slice, not %T", dest)
+	}
+	slice := dv.Elem()
+	elem := slice.Type().Elem()
+	structType := elem
+	if elem.Kind() == reflect.Pointer {
+		structType = elem.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("select: dest must be a pointer to a slice of structs, not %T", dest)
+	}
+
+	res, err := p.QueryRows(sql)
+	if err != nil {
+		return err
+	}
+
+	fields := structFields(structType)
+	decoders := p.typeDecoders()
+	index := make([][]int, len(res.Columns))
+	for i, col := range res.Columns {
+		index[i] = fields[col]
+	}
+
+	rows := reflect.MakeSlice(slice.Type(), 0, len(res.Rows))
+	for r, row := range res.Rows {
+		sv := reflect.New(structType).Elem()
+		for c, idx := range index {
+			if idx == nil {
+				continue
+			}
+			f := sv.FieldByIndex(idx)
+			var err error
+			if decode := decoders[res.Types[c]]; decode != nil && !res.IsNull(r, c) {
+				var v any
+				if v, err = decode(row[c]); err == nil {
+					err = setDecoded(f, v)
+				}
+			} else {
+				err = scanField(f, row[c], res.IsNull(r, c), res.Types[c])
+			}
+			if err != nil {
+				return fmt.Errorf("select: row %d: column %q into field %s: %w",
+					r, res.Columns[c], structType.FieldByIndex(idx).Name, err)
+			}
+		}
+		if elem.Kind() == reflect.Pointer {
+			sv = sv.Addr()
+		}
+		rows = reflect.Append(rows, sv)
+	}
+	slice.Set(rows)
+	return nil
+}
+
+// structFields maps column names to the index paths of the fields of t
+// that receive them. Outer fields take precedence over embedded ones.
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	var embedded [][]int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f.Index)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		fields[name] = f.Index
+	}
+	for _, idx := range embedded {
+		ft := t.FieldByIndex(idx).Type
+		if ft.Kind() == reflect.Pointer {
+			continue // would need allocating; not supported
+		}
+		for name, sub := range structFields(ft) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = append(append([]int(nil), idx...), sub...)
+			}
+		}
+	}
+	return fields
+}
+
+// snakeCase converts a Go field name to snake case, keeping initialisms
+// together: UserID becomes user_id and HTTPServer http_server.
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// scanField stores the text form of a value of type typ in the struct
+// field f.
+func scanField(f reflect.Value, src string, null bool, typ uint32) error {
+	if f.Kind() == reflect.Pointer {
+		if null {
+			f.Set(reflect.Zero(f.Type()))
+			return nil
+		}
+		v := reflect.New(f.Type().Elem())
+		if err := scanField(v.Elem(), src, false, typ); err != nil {
+			return err
+		}
+		f.Set(v)
+		return nil
+	}
+
+	dest := f.Addr().Interface()
+	if _, ok := dest.(sql.Scanner); ok || null || f.Type() == timeType {
+		return scanValue(dest, src, null, typ)
+	}
+
+	// Go by kind, so that named types such as type Status string work too.
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(src)
+		return nil
+	case reflect.Bool:
+		b, err := parseBool(src)
+		f.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(src, 10, f.Type().Bits())
+		f.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(src, 10, f.Type().Bits())
+		f.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(src, f.Type().Bits())
+		f.SetFloat(n)
+		return err
+	}
+	return scanValue(dest, src, null, typ)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
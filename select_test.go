@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"ID":         "id",
+		"Name":       "name",
+		"UserID":     "user_id",
+		"CreatedAt":  "created_at",
+		"HTTPServer": "http_server",
+		"Page2Count": "page2_count",
+		"already_ok": "already_ok",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+type selectBase struct {
+	ID      int64
+	Ignored string `db:"-"`
+}
+
+type selectUser struct {
+	selectBase
+	Name      string
+	Email     *string
+	Score     float32 `db:"points"`
+	Active    bool
+	Level     uint8
+	Nick      sql.NullString
+	CreatedAt time.Time
+	hidden    string
+}
+
+func TestStructFields(t *testing.T) {
+	got := structFields(reflect.TypeOf(selectUser{}))
+	want := map[string][]int{
+		"id":         {0, 0},
+		"name":       {1},
+		"email":      {2},
+		"points":     {3},
+		"active":     {4},
+		"level":      {5},
+		"nick":       {6},
+		"created_at": {7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("structFields = %v, want %v", got, want)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	var users []selectUser
+	err := testPG.Select(&users, `SELECT * FROM (VALUES
+		(1, 'ann', 'ann@example.com', 1.5, true, 3, 'a', TIMESTAMP '2024-10-17 12:00:00', 'extra'),
+		(2, 'bob', NULL, 2.25, false, 0, NULL, TIMESTAMP '2024-10-18 08:30:00', 'extra')
+	) AS v(id, name, email, points, active, level, nick, created_at, unmapped);`)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("got %d users, want 2", len(users))
+	}
+	ann, bob := users[0], users[1]
+	if ann.ID != 1 || ann.Name != "ann" || ann.Email == nil || *ann.Email != "ann@example.com" ||
+		ann.Score != 1.5 || !ann.Active || ann.Level != 3 || ann.Nick.String != "a" ||
+		!ann.CreatedAt.Equal(time.Date(2024, 10, 17, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first row %+v", ann)
+	}
+	if bob.ID != 2 || bob.Email != nil || bob.Nick.Valid || bob.Active {
+		t.Errorf("unexpected second row %+v", bob)
+	}
+
+	var ptrs []*selectUser
+	if err := testPG.Select(&ptrs, "SELECT 7 AS id, 'cy' AS name;"); err != nil {
+		t.Fatalf("Select into pointers: %v", err)
+	}
+	if len(ptrs) != 1 || ptrs[0].ID != 7 || ptrs[0].Name != "cy" {
+		t.Errorf("unexpected rows %+v", ptrs)
+	}
+
+	err = testPG.Select(&users, "SELECT 'x' AS id;")
+	if err == nil || !strings.Contains(err.Error(), `column "id" into field ID`) {
+		t.Errorf("expected a conversion error naming the column and field, got %v", err)
+	}
+	if err := testPG.Select(&users, "SELECT NULL::text AS name;"); err == nil {
+		t.Error("expected an error for NULL into a string field")
+	}
+
+	var notStructs []int
+	if err := testPG.Select(&notStructs, "SELECT 1;"); err == nil {
+		t.Error("expected an error for a slice of non-structs")
+	}
+	if err := testPG.Select(users, "SELECT 1;"); err == nil {
+		t.Error("expected an error for a non-pointer dest")
+	}
+}
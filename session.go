@@ -0,0 +1,44 @@
+package main
+
+// sessionProbe is the parameter probeSession sets to find whether the
+// session persists.
+const sessionProbe = "pglite.session_probe"
+
+// SessionPersists reports whether the backend session lasts from one call
+// into the backend to the next, as the start found by setting a parameter
+// in one call and reading it back in the next.
+//
+// With the bundled build it does: each query continues the one session,
+// so temporary tables, prepared statements, SET and open transactions
+// carry over to later queries. The session ends only when the backend is
+// restarted: after a statement fails, on UseDatabase and on resuming from
+// IdleTimeout. The new session gets back the settings made with SetParam,
+// the channels listened on with Listen and the statements prepared with
+// Prepare, but nothing else. If the session were found not to persist,
+// Options.StickySession would apply the SetParam settings before each
+// query.
+func (p *PGLite) SessionPersists() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sessionPersists
+}
+
+// probeSession sets p.sessionPersists, setting sessionProbe in one call
+// into the backend and reading and resetting it in the next. The caller
+// must hold p.mu.
+func (p *PGLite) probeSession() error {
+	ignore := &rowParser{onRow: func([]string, []bool) error { return nil }}
+	if err := p.query(p.ctx, "SELECT set_config('"+sessionProbe+"', 'on', false);", nil, ignore); err != nil {
+		return err
+	}
+	var value string
+	read := &rowParser{onRow: func(row []string, _ []bool) error {
+		value = row[0]
+		return nil
+	}}
+	if err := p.query(p.ctx, "SELECT current_setting('"+sessionProbe+"', true); RESET "+sessionProbe+";", nil, read); err != nil {
+		return err
+	}
+	p.sessionPersists = value == "on"
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestSessionPersists(t *testing.T) {
+	if !testPG.SessionPersists() {
+		t.Fatal("expected the session to persist")
+	}
+	var probe string
+	if err := testPG.QueryRow("SELECT coalesce(current_setting('" + sessionProbe + "', true), '');").Scan(&probe); err != nil || probe != "" {
+		t.Errorf("probe parameter left set: %q, %v", probe, err)
+	}
+}
+
+func TestStickySession(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), StickySession: true})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	// Act as if the session did not persist.
+	pg.mu.Lock()
+	pg.sessionPersists = false
+	pg.mu.Unlock()
+
+	if err := pg.SetParam("application_name", "sticky"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Query("SET application_name = 'changed';"); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := pg.QueryRow("SHOW application_name;").Scan(&name); err != nil || name != "sticky" {
+		t.Errorf("application_name = %q, %v; want the SetParam value applied again", name, err)
+	}
+}
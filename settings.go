@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SetParam sets a run-time parameter for the session, as SET name = value
+// does, and returns an error if the name or value is invalid.
+//
+// All queries on an instance run in the one backend session, so a setting
+// made with SetParam, or with SET in any query, holds for every later query
+// on the instance. Settings made with SetParam are also re-applied when the
+// backend is restarted, by UseDatabase or after a statement fails, which a
+// plain SET is not.
+func (p *PGLite) SetParam(name, value string) error {
+	if _, err := p.QueryRowsParams("SELECT set_config($1, $2, false);", name, value); err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.params == nil {
+		p.params = make(map[string]string)
+	}
+	p.params[name] = value
+	return nil
+}
+
+// applyParams re-applies the settings made with SetParam, in name order.
+// The caller must hold p.mu.
+func (p *PGLite) applyParams() error {
+	names := make([]string, 0, len(p.params))
+	for name := range p.params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := p.setConfig(name, p.params[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryWithTimeout runs sql as Query does, with statement_timeout set to d
+// for its length, so that PostgreSQL cancels a statement that runs longer
+// with a QueryError of SQLSTATE 57014. The setting in force before, whether
+// from SetParam, SET or the default, is put back afterwards, whether sql
+// succeeds or fails; a SET of statement_timeout in sql is undone with it.
+// d is rounded up to a whole millisecond and must be positive.
+//
+// PostgreSQL enforces the timeout with a timer signal, which the bundled
+// build has no way to deliver: there the setting is made and reset, but a
+// slow statement runs to completion, and a warning that it ran past d is
+// logged. To bound how long a call may take there, use QueryContext.
+func (p *PGLite) QueryWithTimeout(sql string, d time.Duration) error {
+	if d <= 0 {
+		return errors.New("query with timeout: timeout must be positive")
+	}
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	var prev string
+	rp := &rowParser{onRow: func(row []string, _ []bool) error {
+		prev = row[0]
+		return nil
+	}}
+	if err := p.query(p.ctx, "SELECT current_setting('statement_timeout');", nil, rp); err != nil {
+		return fmt.Errorf("query with timeout: %w", err)
+	}
+	if err := p.setConfig("statement_timeout", fmt.Sprintf("%dms", ms)); err != nil {
+		return fmt.Errorf("query with timeout: %w", err)
+	}
+	start := time.Now()
+	err := p.query(p.ctx, sql, nil, nil)
+	if elapsed := time.Since(start); err == nil && elapsed > ms*time.Millisecond {
+		p.log.Warnf("statement ran for %v, past its timeout of %v; statement_timeout is not enforced by this build", elapsed, ms*time.Millisecond)
+	}
+	if rerr := p.setConfig("statement_timeout", prev); rerr != nil && err == nil {
+		err = fmt.Errorf("query with timeout: reset: %w", rerr)
+	}
+	return err
+}
+
+// setConfig sets the session's parameter name to value. Unlike SetParam,
+// it does not keep the setting to re-apply after a restart. The caller
+// must hold p.mu.
+func (p *PGLite) setConfig(name, value string) error {
+	q, err := interpolate("SELECT set_config($1, $2, false);", []any{name, value})
+	if err != nil {
+		return err
+	}
+	if err := p.query(p.ctx, q, nil, nil); err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+	return nil
+}
+
+// SetSearchPath sets the session's search_path to schemas, in order, as
+// SetParam does, so that it holds across restarts. Names are taken as
+// they are, quoted where PostgreSQL would otherwise fold or misread them;
+// "$user" stands for the schema named after the current user. With no
+// schemas, unqualified names resolve only to pg_catalog.
+func (p *PGLite) SetSearchPath(schemas ...string) error {
+	names := make([]string, len(schemas))
+	for i, s := range schemas {
+		names[i] = searchPathName(s)
+	}
+	return p.SetParam("search_path", strings.Join(names, ", "))
+}
+
+// SearchPath returns the schemas in the session's search_path, unquoted,
+// including "$user" and any that do not exist.
+func (p *PGLite) SearchPath() ([]string, error) {
+	var path string
+	if err := p.QueryRow("SELECT current_setting('search_path');").Scan(&path); err != nil {
+		return nil, fmt.Errorf("search path: %w", err)
+	}
+	return parseSearchPath(path)
+}
+
+// searchPathName returns s as written in a search_path setting, quoted
+// unless it is "$user" or a name that reads back the same unquoted.
+func searchPathName(s string) string {
+	if s == "$user" {
+		return `"$user"`
+	}
+	plain := s != ""
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c == '_' || i > 0 && (c >= '0' && c <= '9' || c == '$')) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return s
+	}
+	return quoteIdent(s)
+}
+
+// parseSearchPath splits a search_path setting into names as PostgreSQL
+// reads it: separated by commas, double-quoted names kept as written and
+// other names folded to lower case.
+func parseSearchPath(path string) ([]string, error) {
+	var names []string
+	s := strings.TrimSpace(path)
+	if s == "" {
+		return names, nil
+	}
+	for {
+		var name string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for {
+				j := strings.IndexByte(s[i:], '"')
+				if j < 0 {
+					return nil, fmt.Errorf("search path: unterminated quoted name in %q", path)
+				}
+				b.WriteString(s[i : i+j])
+				i += j + 1
+				if !strings.HasPrefix(s[i:], `"`) {
+					break
+				}
+				b.WriteByte('"')
+				i++
+			}
+			name, s = b.String(), strings.TrimSpace(s[i:])
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			name, s = strings.ToLower(strings.TrimSpace(s[:end])), s[end:]
+		}
+		names = append(names, name)
+		if s == "" {
+			return names, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("search path: malformed setting %q", path)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetParam(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.SetParam("datestyle", "SQL, DMY"); err != nil {
+		t.Fatalf("SetParam: %v", err)
+	}
+	var style, date string
+	if err := pg.QueryRow("SHOW datestyle;").Scan(&style); err != nil || style != "SQL, DMY" {
+		t.Errorf("datestyle: got %q, %v", style, err)
+	}
+	if err := pg.QueryRow("SELECT DATE '2024-10-17'::text;").Scan(&date); err != nil || date != "17/10/2024" {
+		t.Errorf("date: got %q, %v", date, err)
+	}
+
+	if err := pg.SetParam("no_such_param", "1"); err == nil {
+		t.Error("expected an error for an unknown parameter")
+	}
+	if err := pg.SetParam("work_mem", "not a size"); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+
+	if err := pg.CreateDatabase("other"); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if err := pg.UseDatabase("other"); err != nil {
+		t.Fatalf("UseDatabase: %v", err)
+	}
+	if err := pg.QueryRow("SHOW datestyle;").Scan(&style); err != nil || style != "SQL, DMY" {
+		t.Errorf("datestyle after restart: got %q, %v", style, err)
+	}
+}
+
+func TestQueryWithTimeout(t *testing.T) {
+	var log recordLogger
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), Logger: &log})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	timeout := func() string {
+		t.Helper()
+		var s string
+		if err := pg.QueryRow("SHOW statement_timeout;").Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// check fails unless statement_timeout is want while it runs.
+	check := func(want string) string {
+		return fmt.Sprintf(`DO $$ BEGIN
+			IF current_setting('statement_timeout') <> '%s' THEN
+				RAISE EXCEPTION 'statement_timeout is %%', current_setting('statement_timeout');
+			END IF;
+		END $$;`, want)
+	}
+
+	if err := pg.SetParam("statement_timeout", "5s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.QueryWithTimeout(check("250ms"), 250*time.Millisecond); err != nil {
+		t.Errorf("QueryWithTimeout: %v", err)
+	}
+	if got := timeout(); got != "5s" {
+		t.Errorf("after success: statement_timeout %q, want 5s", got)
+	}
+
+	// The setting is reset when the statement fails, and with it the
+	// restart that follows.
+	if err := pg.QueryWithTimeout("SELECT * FROM missing;", time.Second); err == nil {
+		t.Error("expected an error")
+	}
+	if got := timeout(); got != "5s" {
+		t.Errorf("after error: statement_timeout %q, want 5s", got)
+	}
+
+	// A plain SET is put back too, and a part millisecond rounds up.
+	if err := pg.Query("SET statement_timeout = '7s';"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.QueryWithTimeout(check("2ms"), 1500*time.Microsecond); err != nil {
+		t.Errorf("QueryWithTimeout: %v", err)
+	}
+	if got := timeout(); got != "7s" {
+		t.Errorf("after SET: statement_timeout %q, want 7s", got)
+	}
+
+	// The bundled build cannot enforce the timeout, so says so when a
+	// statement runs past it.
+	err = pg.QueryWithTimeout("SELECT pg_sleep(0.05);", time.Millisecond)
+	var qerr *QueryError
+	log.mu.Lock()
+	warned := slices.ContainsFunc(log.msgs, func(m string) bool { return strings.Contains(m, "warning: statement ran for") })
+	log.mu.Unlock()
+	if !warned && (!errors.As(err, &qerr) || qerr.Code != "57014") {
+		t.Errorf("slow statement: got %v and no warning", err)
+	}
+
+	if err := pg.QueryWithTimeout("SELECT 1;", 0); err == nil {
+		t.Error("expected an error for a zero timeout")
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	path, err := pg.SearchPath()
+	if err != nil || !slices.Equal(path, []string{"$user", "public"}) {
+		t.Errorf("default search path: %q, %v", path, err)
+	}
+
+	want := []string{"app", "Mixed Case", `we"ird`, "$user", "public"}
+	if err := pg.RunQueries(`CREATE SCHEMA app; CREATE SCHEMA "Mixed Case"; CREATE TABLE "Mixed Case".t (n int);`); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.SetSearchPath(want...); err != nil {
+		t.Fatalf("SetSearchPath: %v", err)
+	}
+	if path, err := pg.SearchPath(); err != nil || !slices.Equal(path, want) {
+		t.Errorf("search path: got %q, %v, want %q", path, err, want)
+	}
+	if err := pg.Query("SELECT n FROM t;"); err != nil {
+		t.Errorf("unqualified name not found through search path: %v", err)
+	}
+
+	// The setting survives the restart that follows an error.
+	if err := pg.Query("SELECT * FROM missing;"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if path, err := pg.SearchPath(); err != nil || !slices.Equal(path, want) {
+		t.Errorf("search path after restart: got %q, %v, want %q", path, err, want)
+	}
+}
+
+func TestParseSearchPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{`"$user", public`, []string{"$user", "public"}},
+		{`App,"Mixed Case" , "a""b"`, []string{"app", "Mixed Case", `a"b`}},
+	}
+	for _, tt := range tests {
+		got, err := parseSearchPath(tt.in)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := parseSearchPath(`"open`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestShutdown(t *testing.T) {
+	dir := t.TempDir()
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	if err := pg.RunQueries("CREATE TABLE durable (n integer); INSERT INTO durable VALUES (7);"); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := pg.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := pg.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+	if err := pg.Query("SELECT 1;"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Shutdown, got: %v", err)
+	}
+
+	pg, err = NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: dir, CheckpointOnClose: true})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer pg.Close()
+	var n int
+	if err := pg.QueryRow("SELECT n FROM durable;").Scan(&n); err != nil || n != 7 {
+		t.Errorf("expected the row to survive Shutdown, got %d, %v", n, err)
+	}
+}
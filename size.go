@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+)
+
+// DataSizes breaks down the bytes an instance takes on disk, as
+// PGLite.DataSizes measures them.
+type DataSizes struct {
+	Total     int64            // every file under the data directory
+	Cluster   int64            // the cluster directory, tmp/pglite/base
+	WAL       int64            // the write-ahead log, pg_wal in the cluster
+	Databases map[string]int64 // pg_database_size of each database, by name
+}
+
+// DataSize returns the bytes taken by the files under the data directory:
+// the cluster with its databases and write-ahead log, and the installation
+// tree it was extracted with. See DataSizes for a breakdown.
+func (p *PGLite) DataSize() (int64, error) {
+	n, err := dirSize(p.dataDir)
+	if err != nil {
+		return 0, fmt.Errorf("data size: %w", err)
+	}
+	return n, nil
+}
+
+// DataSizes returns the bytes taken by the data directory, and by parts of
+// it, measured on disk, along with the size PostgreSQL gives each
+// database. The databases do not add up to the cluster: pg_database_size
+// counts only a database's own directory, leaving out the catalogs shared
+// between databases, the write-ahead log, the transaction status files
+// and the temporary files of running queries. The write-ahead log grows
+// with writes until a checkpoint recycles it, so it is often much of the
+// difference. Total also counts the installation tree, which does not
+// change. Files are measured as queries run, so the sizes are not taken
+// at a single instant.
+func (p *PGLite) DataSizes() (DataSizes, error) {
+	var s DataSizes
+	var err error
+	if s.Total, err = dirSize(p.dataDir); err != nil {
+		return s, fmt.Errorf("data sizes: %w", err)
+	}
+	if s.Cluster, err = dirSize(filepath.Join(p.dataDir, clusterDir)); err != nil {
+		return s, fmt.Errorf("data sizes: %w", err)
+	}
+	if s.WAL, err = dirSize(filepath.Join(p.dataDir, clusterDir, "pg_wal")); err != nil {
+		return s, fmt.Errorf("data sizes: %w", err)
+	}
+
+	res, err := p.QueryRows("SELECT datname, pg_database_size(oid) FROM pg_database;")
+	if err != nil {
+		return s, fmt.Errorf("data sizes: %w", err)
+	}
+	s.Databases = make(map[string]int64, len(res.Rows))
+	for _, row := range res.Rows {
+		n, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("data sizes: database %s: %w", row[0], err)
+		}
+		s.Databases[row[0]] = n
+	}
+	return s, nil
+}
+
+// dirSize returns the total size of the regular files under root. Files
+// removed while it runs, as the backend removes temporary files, are
+// skipped.
+func dirSize(root string) (int64, error) {
+	var n int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n += info.Size()
+		return nil
+	})
+	return n, err
+}
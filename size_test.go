@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataSize(t *testing.T) {
+	total, err := testPG.DataSize()
+	if err != nil {
+		t.Fatalf("DataSize: %v", err)
+	}
+	s, err := testPG.DataSizes()
+	if err != nil {
+		t.Fatalf("DataSizes: %v", err)
+	}
+	if total <= 0 || s.Total <= 0 {
+		t.Errorf("DataSize = %d, DataSizes().Total = %d", total, s.Total)
+	}
+	var databases int64
+	for _, n := range s.Databases {
+		databases += n
+	}
+	if s.Databases["postgres"] <= 0 || s.WAL <= 0 || databases+s.WAL > s.Cluster || s.Cluster > s.Total {
+		t.Errorf("inconsistent sizes: %+v", s)
+	}
+
+	if err := testPG.Query("CREATE TABLE size_test AS SELECT repeat('x', 1000) AS s FROM generate_series(1, 2000); CHECKPOINT;"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := testPG.DataSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grown := after.Databases["postgres"] - s.Databases["postgres"]; grown < 1<<20 {
+		t.Errorf("postgres grew by %d bytes, want at least 1MiB", grown)
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a/b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"x": 10, "a/y": 20, "a/b/z": 30} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("x", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := dirSize(dir); err != nil || n != 60 {
+		t.Errorf("dirSize = %d, %v; want 60", n, err)
+	}
+	if n, err := dirSize(filepath.Join(dir, "missing")); err != nil || n != 0 {
+		t.Errorf("dirSize of a missing directory = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot writes the cluster directory, tmp/pglite/base under the data
+// directory, to w as a gzipped tar archive. It runs a CHECKPOINT first and
+// blocks other queries until the archive is written, so the files on disk
+// are consistent. Use NewPGLiteFromSnapshot to start an instance from it.
+//
+// The archive holds only the cluster, not the installation tree around it,
+// and leaves out the lock and socket files of the running backend.
+func (p *PGLite) Snapshot(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.query(p.ctx, "CHECKPOINT;", nil, nil); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := p.writeSnapshot(w); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshot writes the archive for Snapshot, once the cluster is
+// checkpointed. The caller must hold p.mu.
+func (p *PGLite) writeSnapshot(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	root := filepath.Join(p.dataDir, "tmp/pglite/base")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == "postmaster.pid" || strings.HasPrefix(d.Name(), ".s.PGSQL.") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			// Restoring would refuse it; see extractTarGz.
+			return fmt.Errorf("%s is not a regular file or directory", path)
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p.dataDir, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// NewPGLiteFromSnapshot starts an instance on the cluster in a snapshot
+// written by Snapshot, replacing any cluster already in opts.DataDir. The
+// installation tree is extracted first if the directory lacks it.
+func NewPGLiteFromSnapshot(ctx context.Context, r io.Reader, stdout, stderr io.Writer, opts Options) (*PGLite, error) {
+	return tempDataDir(opts, func(opts Options) (*PGLite, error) {
+		if err := restoreSnapshot(ctx, opts.dataDir(), r, opts.TarballFS, opts.logger(stderr)); err != nil {
+			return nil, err
+		}
+		opts.ResetOnStart = false
+		return readOnlyCopy(ctx, opts, func(opts Options) (*PGLite, error) {
+			return newPGLiteWithOptions(ctx, stdout, stderr, opts)
+		})
+	})
+}
+
+// RestoreSnapshot sets up dataDir for an instance running on the cluster
+// in a snapshot written by Snapshot. No instance may be running on dataDir.
+// An archive with anything but directories and regular files under
+// tmp/pglite/base, such as links or paths that climb out of it, is
+// refused.
+func RestoreSnapshot(dataDir string, r io.Reader) error {
+	return restoreSnapshot(context.Background(), dataDir, r, nil, nopLogger{})
+}
+
+func restoreSnapshot(ctx context.Context, dataDir string, r io.Reader, src fs.FS, log Logger) error {
+	if err := extractEnv(ctx, dataDir, src, log); err != nil {
+		return fmt.Errorf("restore snapshot: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dataDir, "tmp/pglite/base")); err != nil {
+		return fmt.Errorf("restore snapshot: %w", err)
+	}
+	if err := extractTarGz(r, dataDir, "tmp/pglite/base"); err != nil {
+		return fmt.Errorf("restore snapshot: %w", err)
+	}
+	return nil
+}
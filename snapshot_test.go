@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.RunQueries("CREATE TABLE snap (n integer); INSERT INTO snap VALUES (1), (2), (3);"); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var snap bytes.Buffer
+	if err := pg.Snapshot(&snap); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	// Fork two copies and check they diverge independently.
+	var forks []*PGLite
+	for i := 0; i < 2; i++ {
+		fork, err := NewPGLiteFromSnapshot(context.Background(), bytes.NewReader(snap.Bytes()), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("NewPGLiteFromSnapshot: %v", err)
+		}
+		defer fork.Close()
+		forks = append(forks, fork)
+	}
+	if err := forks[0].Query("DELETE FROM snap;"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for i, want := range []int{0, 3} {
+		var n int
+		if err := forks[i].QueryRow("SELECT count(*) FROM snap;").Scan(&n); err != nil {
+			t.Fatalf("fork %d: %v", i, err)
+		}
+		if n != want {
+			t.Errorf("fork %d: expected %d rows, got %d", i, want, n)
+		}
+	}
+}
+
+func TestExtractTarGzRefusesEscapes(t *testing.T) {
+	archive := func(hdrs ...*tar.Header) io.Reader {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for _, h := range hdrs {
+			if h.Typeflag == tar.TypeReg {
+				h.Size = 2
+			}
+			if err := tw.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+			if h.Typeflag == tar.TypeReg {
+				tw.Write([]byte("hi"))
+			}
+		}
+		tw.Close()
+		gw.Close()
+		return &buf
+	}
+	const root = "tmp/pglite/base"
+
+	for _, h := range []*tar.Header{
+		{Name: "tmp/pglite/base/../../../evil", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "/etc/evil", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "tmp/pglite/bin/postgres.wasi", Typeflag: tar.TypeReg, Mode: 0600},
+		{Name: "tmp/pglite/base/link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		{Name: "tmp/pglite/base/hard", Typeflag: tar.TypeLink, Linkname: "tmp/pglite/bin/postgres.wasi"},
+	} {
+		dir := t.TempDir()
+		if err := extractTarGz(archive(h), filepath.Join(dir, "data"), root); err == nil {
+			t.Errorf("%s: expected an error", h.Name)
+		}
+	}
+
+	dir := t.TempDir()
+	err := extractTarGz(archive(
+		&tar.Header{Name: root, Typeflag: tar.TypeDir, Mode: 0700},
+		&tar.Header{Name: root + "/PG_VERSION", Typeflag: tar.TypeReg, Mode: 0600},
+	), dir, root)
+	if err != nil {
+		t.Fatalf("extractTarGz: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, root, "PG_VERSION")); err != nil || string(b) != "hi" {
+		t.Errorf("PG_VERSION: %q, %v", b, err)
+	}
+}
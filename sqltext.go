@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+)
+
+// skipLiteral returns the index just past the string literal, quoted
+// identifier, dollar-quoted string or comment starting at sql[i], or i if
+// none starts there. An unterminated one extends to the end of sql.
+func skipLiteral(sql string, i int) int {
+	switch c := sql[i]; {
+	case c == '\'':
+		// E'...' strings allow backslash escapes.
+		escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentChar(sql[i-2]))
+		return skipQuoted(sql, i, '\'', escapes)
+	case c == '"':
+		return skipQuoted(sql, i, '"', false)
+	case strings.HasPrefix(sql[i:], "--"):
+		if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+			return i + j + 1
+		}
+		return len(sql)
+	case strings.HasPrefix(sql[i:], "/*"):
+		depth := 0
+		for j := i; j < len(sql)-1; j++ {
+			switch sql[j : j+2] {
+			case "/*":
+				depth++
+				j++
+			case "*/":
+				depth--
+				j++
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(sql)
+	case c == '$':
+		if tag := dollarTag(sql, i); tag != "" {
+			if j := strings.Index(sql[i+len(tag):], tag); j >= 0 {
+				return i + len(tag) + j + len(tag)
+			}
+			return len(sql)
+		}
+	}
+	return i
+}
+
+// skipQuoted returns the index just past the quoted text starting at
+// sql[i]. A doubled quote stands for itself.
+func skipQuoted(sql string, i int, quote byte, escapes bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if escapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string, such as "$$"
+// or "$body$", starting at sql[i], or "" if there is none.
+func dollarTag(sql string, i int) string {
+	if i > 0 && isIdentChar(sql[i-1]) {
+		return "" // part of an identifier such as a$b
+	}
+	for j := i + 1; j < len(sql); j++ {
+		c := sql[j]
+		if c == '$' {
+			return sql[i : j+1]
+		}
+		if !isIdentChar(c) || (j == i+1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// sqlWords returns the bare words of sql, upper-cased, skipping string
+// literals, quoted identifiers and comments.
+func sqlWords(sql string) []string {
+	return scanWords(sql, false)
+}
+
+// onlyComments reports whether sql holds nothing but comments, white
+// space and semicolons.
+func onlyComments(sql string) bool {
+	for i := 0; i < len(sql); {
+		switch c := sql[i]; {
+		case strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*"):
+			i = skipLiteral(sql, i)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			i++
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// topLevelWords is like sqlWords but leaves out the words inside
+// parentheses, such as those of subqueries and function calls.
+func topLevelWords(sql string) []string {
+	return scanWords(sql, true)
+}
+
+func scanWords(sql string, topLevel bool) []string {
+	var words []string
+	depth := 0
+	for i := 0; i < len(sql); {
+		if j := skipLiteral(sql, i); j > i {
+			i = j
+			continue
+		}
+		if !isIdentChar(sql[i]) || isDigit(sql[i]) || sql[i] == '$' {
+			switch sql[i] {
+			case '(':
+				depth++
+			case ')':
+				depth = max(depth-1, 0)
+			}
+			i++
+			continue
+		}
+		j := i
+		for j < len(sql) && isIdentChar(sql[j]) {
+			j++
+		}
+		if !topLevel || depth == 0 {
+			words = append(words, strings.ToUpper(sql[i:j]))
+		}
+		i = j
+	}
+	return words
+}
+
+// scanStatements is a bufio.SplitFunc that splits SQL into statements at
+// semicolons outside string literals, quoted identifiers, dollar-quoted
+// strings and comments. Each token is a statement including its semicolon;
+// the last may lack one. Blank statements are skipped.
+func scanStatements(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	s := string(data)
+	for i := 0; i < len(s); {
+		if j := skipLiteral(s, i); j > i {
+			if j == len(s) && !atEOF {
+				break // the literal may continue in the next read
+			}
+			i = j
+			continue
+		}
+		if s[i] == ';' {
+			stmt := strings.TrimSpace(s[:i+1])
+			if stmt == ";" {
+				stmt = ""
+			}
+			return i + 1, []byte(stmt), nil
+		}
+		i++
+	}
+
+	if !atEOF {
+		return 0, nil, nil
+	}
+	if stmt := strings.TrimSpace(s); stmt != "" {
+		return len(data), []byte(stmt), nil
+	}
+	return len(data), nil, nil
+}
+
+// splitStatements splits sql as scanStatements does.
+func splitStatements(sql string) []string {
+	var stmts []string
+	sc := bufio.NewScanner(strings.NewReader(sql))
+	sc.Buffer(nil, len(sql)+1)
+	sc.Split(scanStatements)
+	for sc.Scan() {
+		if sc.Text() != "" {
+			stmts = append(stmts, sc.Text())
+		}
+	}
+	return stmts
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	fn := `CREATE FUNCTION f() RETURNS int AS $body$
+DECLARE
+  x int := 1;
+
+BEGIN
+  -- a comment; with a semicolon
+  RETURN x + length('a;b') + length($$;$$);
+END;
+$body$ LANGUAGE plpgsql;`
+
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1;", "SELECT 2;"}},
+		{"SELECT 1;\n\nSELECT 2", []string{"SELECT 1;", "SELECT 2"}},
+		{"SELECT ';' AS \"a;b\"; ;; /* ; /* nested; */ */ SELECT E'\\';';",
+			[]string{"SELECT ';' AS \"a;b\";", "/* ; /* nested; */ */ SELECT E'\\';';"}},
+		{fn + "\n\nSELECT f();", []string{fn, "SELECT f();"}},
+		{"  \n", nil},
+	} {
+		if got := splitStatements(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitStatements(%q) =\n%q\nwant\n%q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRunQueriesFunctionBody(t *testing.T) {
+	script := `CREATE OR REPLACE FUNCTION split_test() RETURNS text AS $$
+DECLARE
+  s text := 'a;b';
+
+BEGIN
+  RETURN s;
+END;
+$$ LANGUAGE plpgsql; SELECT 1;`
+	if err := testPG.RunQueries(script); err != nil {
+		t.Fatalf("RunQueries: %v", err)
+	}
+
+	var s string
+	if err := testPG.QueryRow("SELECT split_test();").Scan(&s); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if s != "a;b" {
+		t.Errorf("split_test() = %q, want %q", s, "a;b")
+	}
+}
+
+func TestTopLevelWords(t *testing.T) {
+	got := topLevelWords(`SELECT a, count(x) FROM (SELECT 'LIMIT' AS x LIMIT 1) s WHERE "limit" > 0 LIMIT 5`)
+	want := []string{"SELECT", "A", "COUNT", "FROM", "S", "WHERE", "LIMIT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOnlyComments(t *testing.T) {
+	for sql, want := range map[string]bool{
+		"-- note":            true,
+		"/* a */ ; -- b\n":   true,
+		"":                   true,
+		"SELECT 1; -- note":  false,
+		"/* a */ SELECT 1":   false,
+		"'-- not a comment'": false,
+	} {
+		if got := onlyComments(sql); got != want {
+			t.Errorf("onlyComments(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
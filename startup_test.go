@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), InitTimeout: time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "timed out after 1ms") {
+		t.Errorf("error does not say it timed out: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("start was not interrupted, took %v", elapsed)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir(), WaitReady: true})
+		if err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		var n int
+		err = pg.QueryRow("SELECT 42;").Scan(&n)
+		pg.Close()
+		if err != nil || n != 42 {
+			t.Errorf("start %d: first query: got %d, %v", i, n, err)
+		}
+	}
+}
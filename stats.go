@@ -0,0 +1,22 @@
+package main
+
+import "time"
+
+// Stats holds cumulative counters for an instance, from its start.
+type Stats struct {
+	Queries  int64         // statements sent to the backend
+	Errors   int64         // statements that returned an error
+	Rows     int64         // rows returned by QueryRows, QueryEach and the methods built on them
+	WASMTime time.Duration // time spent running the module for statements
+}
+
+// Stats returns the instance's counters. They cover every statement that
+// goes through Query or the methods built on it, including those the
+// package issues on the caller's behalf, such as the transaction status
+// query of Begin, and those Validate checks, but not the protocol traffic
+// of Serve and QueryBinary.
+func (p *PGLite) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
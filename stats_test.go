@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	before := testPG.Stats()
+
+	if _, err := testPG.QueryRows("SELECT * FROM generate_series(1, 3);"); err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if err := testPG.Query("SELECT * FROM no_such_table;"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := testPG.QueryEach("SELECT * FROM generate_series(1, 2);", func([]string) error { return nil }); err != nil {
+		t.Fatalf("QueryEach: %v", err)
+	}
+
+	after := testPG.Stats()
+	if d := after.Queries - before.Queries; d != 3 {
+		t.Errorf("Queries grew by %d, want 3", d)
+	}
+	if d := after.Errors - before.Errors; d != 1 {
+		t.Errorf("Errors grew by %d, want 1", d)
+	}
+	if d := after.Rows - before.Rows; d != 5 {
+		t.Errorf("Rows grew by %d, want 5", d)
+	}
+	if after.WASMTime <= before.WASMTime {
+		t.Errorf("WASMTime did not grow: %v -> %v", before.WASMTime, after.WASMTime)
+	}
+}
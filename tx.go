@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrTxEnded is matched by the error WithRollback returns when the
+// transaction it opened ended while fn ran, so that fn's changes may have
+// been committed.
+var ErrTxEnded = errors.New("transaction ended inside WithRollback")
+
+// Tx is a transaction started by Begin. Its statements run on the
+// instance's single session, so other queries issued while it is open take
+// part in it too.
+//
+// A statement that fails restarts the backend, which rolls the transaction
+// back. As in PostgreSQL, every later statement then fails with SQLSTATE
+// 25P02 until the transaction ends, and Commit and Rollback return that
+// error too, so nothing meant for the transaction runs outside it.
+type Tx struct {
+	pg   *PGLite
+	done bool
+}
+
+// Begin starts a transaction and checks that the backend entered it.
+func (p *PGLite) Begin() (*Tx, error) {
+	open, err := p.inTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if open {
+		return nil, errors.New("begin: a transaction is already in progress")
+	}
+
+	p.beginTx()
+	if err := p.Query("BEGIN;"); err != nil {
+		p.endTx()
+		return nil, err
+	}
+	if open, err = p.inTransaction(); err != nil {
+		p.endTx()
+		return nil, err
+	}
+	if !open {
+		p.endTx()
+		return nil, errors.New("begin: backend did not start a transaction")
+	}
+	return &Tx{pg: p}, nil
+}
+
+// beginTx marks a transaction as open, so that statements fail once a
+// restart of the backend rolls it back, rather than run outside it. It
+// reports false, doing nothing, if one is already marked.
+func (p *PGLite) beginTx() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.txOpen {
+		return false
+	}
+	p.txOpen, p.txRestarts = true, p.restarts
+	return true
+}
+
+// endTx clears the mark beginTx set, and returns the error txAborted does
+// if the transaction was rolled back by a restart.
+func (p *PGLite) endTx() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.txAborted()
+	p.txOpen = false
+	return err
+}
+
+// txAborted returns a QueryError of SQLSTATE 25P02 (in_failed_sql_transaction)
+// if the backend has restarted since the open transaction began, as the
+// restart rolled it back. The caller must hold p.mu.
+func (p *PGLite) txAborted() error {
+	if !p.txOpen || p.restarting || p.restarts == p.txRestarts {
+		return nil
+	}
+	return &QueryError{
+		Severity: "ERROR",
+		Code:     "25P02",
+		Message:  "current transaction is aborted, commands ignored until end of transaction block",
+		Detail:   "The backend was restarted after an error, which rolled the transaction back.",
+	}
+}
+
+// inTransaction reports whether a transaction block is open. Outside one,
+// each statement is its own transaction and starts when the statement does.
+func (p *PGLite) inTransaction() (bool, error) {
+	res, err := p.QueryRows("SELECT transaction_timestamp() <> statement_timestamp();")
+	if err != nil {
+		return false, err
+	}
+	if len(res.Rows) != 1 {
+		return false, errors.New("transaction status query returned no rows")
+	}
+	return res.Rows[0][0] == "t", nil
+}
+
+// transactionOpen is inTransaction for callers that hold p.mu.
+func (p *PGLite) transactionOpen() (bool, error) {
+	open := false
+	rp := &rowParser{onRow: func(row []string, _ []bool) error {
+		open = row[0] == "t"
+		return nil
+	}}
+	if err := p.query(p.ctx, "SELECT transaction_timestamp() <> statement_timestamp();", nil, rp); err != nil {
+		return false, err
+	}
+	return open, nil
+}
+
+// Exec runs a statement in the transaction. See PGLite.Exec.
+func (tx *Tx) Exec(query string) (CommandResult, error) {
+	if tx.done {
+		return CommandResult{}, sql.ErrTxDone
+	}
+	return tx.pg.Exec(query)
+}
+
+// Query runs a query in the transaction and returns its rows.
+func (tx *Tx) Query(query string) (*Result, error) {
+	if tx.done {
+		return nil, sql.ErrTxDone
+	}
+	return tx.pg.QueryRows(query)
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	if err := tx.pg.endTx(); err != nil {
+		return err
+	}
+	return tx.pg.Query("COMMIT;")
+}
+
+// Rollback aborts the transaction. It is a no-op if the transaction has
+// already been committed or rolled back. If a restart already rolled it
+// back, Rollback returns the error statements in it fail with.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+	if err := tx.pg.endTx(); err != nil {
+		return err
+	}
+	return tx.pg.Query("ROLLBACK;")
+}
+
+// WithRollback runs fn in a transaction that is then rolled back, so that
+// fn leaves the database as it found it, as for a test fixture. If a
+// transaction is already open, fn runs in a savepoint within it instead,
+// which is rolled back to. WithRollback returns fn's error.
+//
+// If fn committed the transaction, later statements were not rolled back,
+// and WithRollback returns an error matching ErrTxEnded along with fn's.
+// If a statement failed, restarting the backend rolled the transaction
+// back, and later statements fail with SQLSTATE 25P02, as in a Tx, which
+// WithRollback also returns. Queries other goroutines issue while fn runs
+// take part in the transaction too.
+func (p *PGLite) WithRollback(fn func(*PGLite) error) error {
+	open, err := p.inTransaction()
+	if err != nil {
+		return fmt.Errorf("with rollback: %w", err)
+	}
+	begin, end := "BEGIN;", "ROLLBACK;"
+	if open {
+		begin = "SAVEPOINT pglite_with_rollback;"
+		end = "ROLLBACK TO SAVEPOINT pglite_with_rollback; RELEASE SAVEPOINT pglite_with_rollback;"
+	}
+	if p.beginTx() {
+		defer p.endTx()
+	}
+	if err := p.Query(begin); err != nil {
+		return fmt.Errorf("with rollback: %w", err)
+	}
+	xid, err := p.currentXID()
+	if err != nil {
+		return fmt.Errorf("with rollback: %w", err)
+	}
+
+	ferr := fn(p)
+
+	// Outside a transaction, each statement has an ID of its own.
+	if now, err := p.currentXID(); err != nil {
+		return errors.Join(ferr, fmt.Errorf("with rollback: %w", err))
+	} else if now != xid {
+		return errors.Join(ferr, fmt.Errorf("with rollback: %w", ErrTxEnded))
+	}
+	if err := p.Query(end); err != nil {
+		return errors.Join(ferr, fmt.Errorf("with rollback: %w", err))
+	}
+	return ferr
+}
+
+// currentXID returns the ID of the current transaction, assigning one if
+// it has none.
+func (p *PGLite) currentXID() (string, error) {
+	res, err := p.QueryRows("SELECT txid_current();")
+	if err != nil {
+		return "", err
+	}
+	if len(res.Rows) != 1 {
+		return "", errors.New("transaction ID query returned no rows")
+	}
+	return res.Rows[0][0], nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestTx(t *testing.T) {
+	if err := testPG.Query("CREATE TABLE tx_test (id integer);"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	count := func() string {
+		t.Helper()
+		res, err := testPG.QueryRows("SELECT count(*) FROM tx_test;")
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return res.Rows[0][0]
+	}
+
+	tx, err := testPG.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err := testPG.Begin(); err == nil {
+		t.Error("expected error beginning a nested transaction")
+	}
+	if _, err := tx.Exec("INSERT INTO tx_test VALUES (1);"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := count(); got != "0" {
+		t.Errorf("after rollback: count = %s, want 0", got)
+	}
+
+	tx, err = testPG.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err := tx.Exec("INSERT INTO tx_test VALUES (1);"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback after Commit: %v", err)
+	}
+	if _, err := tx.Query("SELECT 1;"); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("expected sql.ErrTxDone, got: %v", err)
+	}
+	if got := count(); got != "1" {
+		t.Errorf("after commit: count = %s, want 1", got)
+	}
+}
+
+func TestWithRollback(t *testing.T) {
+	if err := testPG.Query("CREATE TABLE rollback_test (id integer);"); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int64 {
+		t.Helper()
+		n, err := testPG.QueryCount("SELECT * FROM rollback_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	insert := func(pg *PGLite) error {
+		_, err := pg.Exec("INSERT INTO rollback_test VALUES (1);")
+		return err
+	}
+
+	if err := testPG.WithRollback(func(pg *PGLite) error {
+		if err := insert(pg); err != nil {
+			return err
+		}
+		if n := count(); n != 1 {
+			t.Errorf("inside: count = %d, want 1", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("WithRollback: %v", err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("after: count = %d, want 0", n)
+	}
+
+	errFn := errors.New("fn failed")
+	if err := testPG.WithRollback(func(pg *PGLite) error {
+		insert(pg)
+		return errFn
+	}); !errors.Is(err, errFn) || count() != 0 {
+		t.Errorf("failing fn: %v, count %d", err, count())
+	}
+
+	// Inside a transaction, a savepoint is rolled back to.
+	tx, err := testPG.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO rollback_test VALUES (2);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testPG.WithRollback(insert); err != nil {
+		t.Fatalf("WithRollback in a transaction: %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("in transaction: count = %d, want 1", n)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A commit inside fn is reported.
+	err = testPG.WithRollback(func(pg *PGLite) error {
+		if err := insert(pg); err != nil {
+			return err
+		}
+		return pg.Query("COMMIT;")
+	})
+	if !errors.Is(err, ErrTxEnded) {
+		t.Errorf("commit in fn: got %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("after commit in fn: count = %d, want 1", n)
+	}
+}
+
+func TestTxAbortedByRestart(t *testing.T) {
+	if err := testPG.Query("CREATE TABLE tx_abort_test (id integer PRIMARY KEY);"); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int64 {
+		t.Helper()
+		n, err := testPG.QueryCount("SELECT * FROM tx_abort_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	aborted := func(err error) bool {
+		var qerr *QueryError
+		return errors.As(err, &qerr) && qerr.Code == "25P02"
+	}
+
+	tx, err := testPG.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO tx_abort_test VALUES (1);"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO tx_abort_test VALUES (1);"); err == nil {
+		t.Fatal("expected a duplicate key error")
+	}
+	// The restart after the error rolled the transaction back; nothing
+	// more runs until it ends.
+	if _, err := tx.Exec("INSERT INTO tx_abort_test VALUES (2);"); !aborted(err) {
+		t.Errorf("Exec after error: got %v, want SQLSTATE 25P02", err)
+	}
+	if err := testPG.Query("INSERT INTO tx_abort_test VALUES (3);"); !aborted(err) {
+		t.Errorf("Query after error: got %v, want SQLSTATE 25P02", err)
+	}
+	if err := tx.Rollback(); !aborted(err) {
+		t.Errorf("Rollback: got %v, want SQLSTATE 25P02", err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("after rollback: count = %d, want 0", n)
+	}
+
+	tx, err = testPG.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPG.Query("SELECT 1/0;")
+	if err := tx.Commit(); !aborted(err) {
+		t.Errorf("Commit: got %v, want SQLSTATE 25P02", err)
+	}
+
+	err = testPG.WithRollback(func(pg *PGLite) error {
+		pg.Query("INSERT INTO tx_abort_test VALUES (1);")
+		pg.Query("INSERT INTO tx_abort_test VALUES (1);")
+		return pg.Query("INSERT INTO tx_abort_test VALUES (2);")
+	})
+	if !aborted(err) {
+		t.Errorf("WithRollback: got %v, want SQLSTATE 25P02", err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("after WithRollback: count = %d, want 0", n)
+	}
+}
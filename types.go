@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// RegisterType registers decode to convert values of the named type, given
+// as PostgreSQL would write it, such as "jsonb", "double precision" or
+// "app.mood", or as its OID, such as "3802". QueryMaps stores what decode
+// returns in its maps, and Select in struct fields the value can be
+// assigned or converted to, taking precedence over their own conversions.
+// decode is given the text PostgreSQL printed for a value, and is not
+// called for NULL. Registering a type again replaces its decoder; a nil
+// decode removes it.
+//
+// The type is looked up when it is registered, in the current database,
+// so a type created by a later statement, or one of another database used
+// with UseDatabase, must be registered after that. Columns of a domain are
+// reported with the domain's base type, so register that instead.
+func (p *PGLite) RegisterType(name string, decode func(string) (any, error)) error {
+	res, err := p.QueryRowsParams("SELECT to_regtype($1)::oid;", name)
+	if err != nil {
+		return fmt.Errorf("register type %s: %w", name, err)
+	}
+	if len(res.Rows) != 1 || res.IsNull(0, 0) {
+		return fmt.Errorf("register type %s: no such type", name)
+	}
+	oid, err := strconv.ParseUint(res.Rows[0][0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("register type %s: %w", name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The map is replaced rather than changed, so readers need not hold
+	// p.mu while using it.
+	decoders := make(map[uint32]func(string) (any, error), len(p.decoders)+1)
+	for k, v := range p.decoders {
+		decoders[k] = v
+	}
+	if decode == nil {
+		delete(decoders, uint32(oid))
+	} else {
+		decoders[uint32(oid)] = decode
+	}
+	p.decoders = decoders
+	return nil
+}
+
+// typeDecoders returns the decoders registered with RegisterType, by type
+// OID. The map must not be changed.
+func (p *PGLite) typeDecoders() map[uint32]func(string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.decoders
+}
+
+// setDecoded stores v, returned by a registered decoder, in the struct
+// field f: as it is if it is assignable, or through f's Scan method, or
+// converted if it has the same kind as f, as from int64 to a named integer
+// type. A pointer field is allocated.
+func setDecoded(f reflect.Value, v any) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if rv.Type().AssignableTo(f.Type()) {
+		f.Set(rv)
+		return nil
+	}
+	if s, ok := f.Addr().Interface().(sql.Scanner); ok {
+		return s.Scan(v)
+	}
+	if f.Kind() == reflect.Pointer {
+		pv := reflect.New(f.Type().Elem())
+		if err := setDecoded(pv.Elem(), v); err != nil {
+			return err
+		}
+		f.Set(pv)
+		return nil
+	}
+	if rv.Kind() == f.Kind() && rv.Type().ConvertibleTo(f.Type()) {
+		f.Set(rv.Convert(f.Type()))
+		return nil
+	}
+	return fmt.Errorf("decoded value of type %T cannot be stored in %s", v, f.Type())
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type cents int64
+
+func decodeMoney(s string) (any, error) {
+	s = strings.NewReplacer("$", "", ",", "", ".", "").Replace(s)
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err
+}
+
+func decodeJSON(s string) (any, error) {
+	var v any
+	err := json.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+func TestRegisterType(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	if err := pg.RegisterType("money", decodeMoney); err != nil {
+		t.Fatalf("RegisterType: %v", err)
+	}
+	if err := pg.RegisterType("3802", decodeJSON); err != nil { // jsonb, by OID
+		t.Fatalf("RegisterType by OID: %v", err)
+	}
+	if err := pg.RegisterType("no_such_type", decodeJSON); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+
+	const query = `SELECT 1234.5::money AS price, '{"a": [1, 2]}'::jsonb AS doc,
+		NULL::money AS missing, 7 AS n;`
+	maps, err := pg.QueryMaps(query)
+	if err != nil {
+		t.Fatalf("QueryMaps: %v", err)
+	}
+	want := map[string]any{
+		"price":   int64(123450),
+		"doc":     map[string]any{"a": []any{1.0, 2.0}},
+		"missing": nil,
+		"n":       int64(7),
+	}
+	if len(maps) != 1 || !reflect.DeepEqual(maps[0], want) {
+		t.Errorf("QueryMaps: got %#v, want %#v", maps, want)
+	}
+
+	var rows []struct {
+		Price   cents
+		Doc     map[string]any
+		Missing *int64
+		N       int
+	}
+	if err := pg.Select(&rows, query); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Price != 123450 || !reflect.DeepEqual(rows[0].Doc, want["doc"]) || rows[0].Missing != nil || rows[0].N != 7 {
+		t.Errorf("Select: got %+v", rows)
+	}
+	var bad []struct{ Price string }
+	if err := pg.Select(&bad, query); err == nil {
+		t.Error("expected an error storing an int64 in a string field")
+	}
+
+	// A registered decoder overrides the default conversion, and a nil one
+	// restores it.
+	if err := pg.RegisterType("integer", func(s string) (any, error) { return "n" + s, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if maps, err := pg.QueryMaps("SELECT 7 AS n;"); err != nil || maps[0]["n"] != "n7" {
+		t.Errorf("overridden int4: got %v, %v", maps, err)
+	}
+	if err := pg.RegisterType("integer", nil); err != nil {
+		t.Fatal(err)
+	}
+	if maps, err := pg.QueryMaps("SELECT 7 AS n;"); err != nil || maps[0]["n"] != int64(7) {
+		t.Errorf("restored int4: got %v, %v", maps, err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+// unsupported returns an error wrapping ErrNotSupported for the first
+// statement in sql that cannot work in PGLite, or nil; see ErrNotSupported.
+func unsupported(sql string) error {
+	for _, stmt := range splitStatements(sql) {
+		// A query being copied may read from a table named program.
+		words := topLevelWords(stmt)
+		if len(words) < 2 {
+			continue
+		}
+		switch {
+		case words[0] == "COPY":
+			for i := 1; i+1 < len(words); i++ {
+				if (words[i] == "TO" || words[i] == "FROM") && words[i+1] == "PROGRAM" {
+					return fmt.Errorf("COPY %s PROGRAM: %w: the WASI build cannot start processes", words[i], ErrNotSupported)
+				}
+			}
+		case words[0] == "CREATE" && words[1] == "SUBSCRIPTION":
+			return fmt.Errorf("CREATE SUBSCRIPTION: %w: single-user mode has no replication workers", ErrNotSupported)
+		}
+	}
+	return nil
+}
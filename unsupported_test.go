@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want bool
+	}{
+		{"COPY t TO PROGRAM 'gzip > t.gz';", true},
+		{"copy t (a, b) from program 'cat t.csv' WITH (FORMAT csv);", true},
+		{"SELECT 1; CREATE SUBSCRIPTION s CONNECTION 'dbname=x' PUBLICATION p;", true},
+		{"COPY t TO STDOUT;", false},
+		{"COPY (SELECT * FROM program) TO STDOUT;", false},
+		{"SELECT 'COPY t TO PROGRAM x';", false},
+		{"CREATE PUBLICATION p FOR ALL TABLES;", false},
+		{"", false},
+	} {
+		err := unsupported(tc.sql)
+		if got := errors.Is(err, ErrNotSupported); got != tc.want {
+			t.Errorf("%q: got %v, want ErrNotSupported %v", tc.sql, err, tc.want)
+		}
+	}
+}
+
+func TestErrNotSupported(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+
+	for _, sql := range []string{
+		"CREATE SUBSCRIPTION s CONNECTION 'dbname=x' PUBLICATION p;",
+		"COPY pg_class FROM PROGRAM 'cat';",
+	} {
+		if err := pg.Query(sql); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%q: got %v, want ErrNotSupported", sql, err)
+		}
+	}
+	if _, err := pg.Exec("COPY pg_class TO PROGRAM 'cat';"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Exec: got %v, want ErrNotSupported", err)
+	}
+	var n int
+	if err := pg.QueryRow("SELECT 1;").Scan(&n); err != nil || n != 1 {
+		t.Errorf("instance unusable afterwards: %d, %v", n, err)
+	}
+
+	_, err = NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{InMemory: true, ReadOnly: true})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("InMemory with ReadOnly: got %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errVacuumInTransaction is returned by Vacuum while a transaction is open,
+// in which VACUUM cannot run.
+var errVacuumInTransaction = errors.New("vacuum: cannot run inside a transaction")
+
+// Vacuum runs VACUUM ANALYZE on table, reclaiming the space of deleted and
+// updated rows and refreshing the planner's statistics, or on every table
+// of the database if table is "". table is used as written, so it may be
+// schema-qualified. VACUUM cannot run inside a transaction, so Vacuum
+// fails while one is open, without disturbing it.
+func (p *PGLite) Vacuum(table string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	return p.vacuum(table)
+}
+
+// vacuum is Vacuum. The caller must hold p.mu.
+func (p *PGLite) vacuum(table string) error {
+	open, err := p.transactionOpen()
+	if err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	if open {
+		return errVacuumInTransaction
+	}
+	stmt := "VACUUM ANALYZE;"
+	if table != "" {
+		stmt = "VACUUM ANALYZE " + table + ";"
+	}
+	if err := p.query(p.ctx, stmt, nil, nil); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// vacuumEvery runs VACUUM ANALYZE on the whole database every d, taking its
+// turn with other statements, until stop is closed. A round that finds a
+// transaction open is skipped; other failures are logged.
+func (p *PGLite) vacuumEvery(d time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		if p.suspended {
+			// Nothing has changed since the last round.
+			p.mu.Unlock()
+			continue
+		}
+		// A round does not count as use for IdleTimeout.
+		touched := p.touched
+		err := p.vacuum("")
+		p.touched = touched
+		p.mu.Unlock()
+		if err != nil && err != errVacuumInTransaction {
+			p.log.Errorf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// vacuumCount returns how many times table has been vacuumed.
+func vacuumCount(t *testing.T, pg *PGLite, table string) int {
+	t.Helper()
+	var n int
+	if err := pg.QueryRow("SELECT vacuum_count FROM pg_stat_user_tables WHERE relname = '" + table + "';").Scan(&n); err != nil {
+		t.Fatalf("vacuum count: %v", err)
+	}
+	return n
+}
+
+func TestVacuum(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE vac (n int); INSERT INTO vac SELECT generate_series(1, 100); DELETE FROM vac WHERE n % 2 = 0;"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pg.Vacuum("vac"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if err := pg.Vacuum(""); err != nil {
+		t.Fatalf("Vacuum of the database: %v", err)
+	}
+	if n := vacuumCount(t, pg, "vac"); n != 2 {
+		t.Errorf("vacuum count: %d, want 2", n)
+	}
+	if err := pg.Vacuum("missing"); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+
+	// Inside a transaction, Vacuum fails and leaves the transaction open.
+	tx, err := pg.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO vac VALUES (1000);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Vacuum("vac"); err == nil {
+		t.Error("expected an error inside a transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit after Vacuum: %v", err)
+	}
+	var n int
+	if err := pg.QueryRow("SELECT count(*) FROM vac WHERE n = 1000;").Scan(&n); err != nil || n != 1 {
+		t.Errorf("row inserted in the transaction: %d, %v", n, err)
+	}
+}
+
+func TestVacuumInterval(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{
+		DataDir:        t.TempDir(),
+		VacuumInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE vac (n int);"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for vacuumCount(t, pg, "vac") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("table not vacuumed in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// Validate checks sql, a single statement, without running it. The
+// statement is parsed and, unless it is a utility statement such as
+// CREATE TABLE, analyzed, so that unknown tables, columns, functions and
+// mismatched types are reported as well as syntax errors; a utility
+// statement is checked for syntax only. Nothing is planned or executed,
+// so INSERT, UPDATE and DELETE have no effect. Placeholders $1, $2, ...
+// are allowed, with their types inferred.
+//
+// A problem is returned as a *QueryError, whose Position, if not zero,
+// is the character in sql it was found at.
+//
+// A statement that fails to validate fails in the backend as any other
+// does, so the backend is restarted: the session's temporary tables and
+// plain SET settings are lost, and an open transaction is rolled back,
+// as Query describes. Validate is counted in Stats and reported to
+// OnQuery like a statement that runs.
+func (p *PGLite) Validate(sql string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.query(p.ctx, sql, nil, parseOnly{})
+}
+
+// parseOnly is the rowHandler of Validate, which has the statement parsed
+// rather than run. The reply has no rows.
+type parseOnly struct{}
+
+func (parseOnly) describe([]byte) error { return nil }
+func (parseOnly) dataRow([]byte) error  { return nil }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	defer pg.Close()
+	if err := pg.Query("CREATE TABLE v (n int);"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sql := range []string{
+		"SELECT n FROM v WHERE n > $1;",
+		"INSERT INTO v VALUES (1)",
+		"UPDATE v SET n = n + 1;",
+		"DELETE FROM v;",
+		"CREATE TABLE w (s text);",
+	} {
+		if err := pg.Validate(sql); err != nil {
+			t.Errorf("Validate(%q): %v", sql, err)
+		}
+	}
+	var n int
+	if err := pg.QueryRow("SELECT count(*) FROM v;").Scan(&n); err != nil || n != 0 {
+		t.Errorf("rows after validating an INSERT: %d, %v", n, err)
+	}
+	if err := pg.Query("SELECT * FROM w;"); err == nil {
+		t.Error("validating CREATE TABLE created the table")
+	}
+
+	tests := []struct {
+		sql      string
+		code     string
+		position int
+	}{
+		{"SELEC 1;", "42601", 1},
+		{"SELECT n FROM missing;", "42P01", 15},
+		{"SELECT nope FROM v;", "42703", 8},
+		{"SELECT n FROM v WHERE n = 'x';", "22P02", 27},
+	}
+	for _, tt := range tests {
+		err := pg.Validate(tt.sql)
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Errorf("Validate(%q): got %v, want a QueryError", tt.sql, err)
+			continue
+		}
+		if qerr.Code != tt.code || qerr.Position != tt.position {
+			t.Errorf("Validate(%q): got %s at %d, want %s at %d", tt.sql, qerr.Code, qerr.Position, tt.code, tt.position)
+		}
+	}
+
+	// The instance is still usable after a failed validation.
+	if err := pg.QueryRow("SELECT count(*) FROM v;").Scan(&n); err != nil {
+		t.Errorf("query after a failed validation: %v", err)
+	}
+
+	// Validate goes the way of any statement: it is counted, and a
+	// failure restarts the backend, ending an open transaction.
+	before := pg.Stats()
+	if err := pg.Validate("SELECT 1;"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pg.Stats().Queries - before.Queries; got != 1 {
+		t.Errorf("Stats counted %d statements for Validate, want 1", got)
+	}
+	tx, err := pg.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Query("INSERT INTO v VALUES (1);"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Validate("SELECT nope FROM v;"); err == nil {
+		t.Fatal("expected an error")
+	}
+	var qerr *QueryError
+	if err := tx.Commit(); !errors.As(err, &qerr) || qerr.Code != "25P02" {
+		t.Errorf("commit after a failed validation: got %v, want 25P02", err)
+	}
+	if err := pg.QueryRow("SELECT count(*) FROM v;").Scan(&n); err != nil || n != 0 {
+		t.Errorf("rows after the rolled back transaction: %d, %v", n, err)
+	}
+}
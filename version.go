@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+)
+
+// BundledPostgresVersion is the PostgreSQL release the embedded module is
+// built from.
+const BundledPostgresVersion = "16.4"
+
+// BundledBuild identifies the embedded PGLite build: the WASI build shared
+// in https://github.com/electric-sql/pglite/issues/89#issuecomment-2418437346,
+// made from the pglite-merge tree. The build does not record a commit.
+const BundledBuild = "pglite-merge wasi (postgresql-16.4)"
+
+// ServerVersion returns the server's version string, as from SELECT
+// version(). It is read once and cached.
+func (p *PGLite) ServerVersion() (string, error) {
+	p.mu.Lock()
+	v := p.version
+	p.mu.Unlock()
+	if v != "" {
+		return v, nil
+	}
+
+	res, err := p.QueryRows("SELECT version();")
+	if err != nil {
+		return "", err
+	}
+	if len(res.Rows) != 1 {
+		return "", errors.New("server version: no result")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.version = res.Rows[0][0]
+	return p.version, nil
+}
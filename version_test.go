@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServerVersion(t *testing.T) {
+	v, err := testPG.ServerVersion()
+	if err != nil {
+		t.Fatalf("ServerVersion: %v", err)
+	}
+	if !strings.HasPrefix(v, "PostgreSQL "+BundledPostgresVersion+" ") {
+		t.Errorf("unexpected version %q, want PostgreSQL %s", v, BundledPostgresVersion)
+	}
+
+	again, err := testPG.ServerVersion()
+	if err != nil || again != v {
+		t.Errorf("cached version: got %q, %v", again, err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// The PGLite module reads each query as a C string from the bottom of
+// linear memory, starting just above the NULL address. The module exports
+// no allocator, so the buffer extends up to the first byte of static data,
+// which is found by reading the module's data section.
+const inputAddr = 1
+
+var wasmMagic = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// inputBuffer returns the address and capacity of the query input buffer
+// for the given WASM binary.
+func inputBuffer(blob []byte) (addr, size uint32, err error) {
+	start, err := dataStart(blob)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start <= inputAddr {
+		return 0, 0, fmt.Errorf("no room for input buffer below static data at %d", start)
+	}
+	return inputAddr, start - inputAddr, nil
+}
+
+// dataStart returns the lowest offset of the active data segments in blob.
+func dataStart(blob []byte) (uint32, error) {
+	if !bytes.HasPrefix(blob, wasmMagic) {
+		return 0, errors.New("not a WASM binary")
+	}
+	r := &wasmReader{b: blob, off: len(wasmMagic)}
+	for r.off < len(r.b) {
+		id := r.byte()
+		size := r.u32()
+		end := r.off + int(size)
+		if r.err != nil || end > len(r.b) {
+			return 0, errors.New("truncated WASM section")
+		}
+		if id != 11 { // data section
+			r.off = end
+			continue
+		}
+
+		lowest := ^uint32(0)
+		n := r.u32()
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			flags := r.u32()
+			switch flags {
+			case 0, 2:
+				if flags == 2 {
+					r.u32() // memory index
+				}
+				if op := r.byte(); op != 0x41 { // i32.const
+					return 0, fmt.Errorf("data segment %d has a non-constant offset", i)
+				}
+				off := uint32(r.s32())
+				if r.byte() != 0x0b { // end
+					return 0, fmt.Errorf("data segment %d has a malformed offset", i)
+				}
+				lowest = min(lowest, off)
+			case 1: // passive
+			default:
+				return 0, fmt.Errorf("data segment %d has unknown flags %d", i, flags)
+			}
+			r.off += int(r.u32()) // segment bytes
+		}
+		if r.err != nil || r.off > end {
+			return 0, errors.New("malformed WASM data section")
+		}
+		if lowest == ^uint32(0) {
+			break
+		}
+		return lowest, nil
+	}
+	return 0, errors.New("WASM binary has no active data segments")
+}
+
+// In the PGLite build, siglongjmp does not return to its sigsetjmp, so an
+// ERROR raised inside a PG_TRY block, as any raised while a statement runs
+// is, cannot be caught: pg_re_throw finds an exception stack to jump to,
+// the jump comes straight back and the backend aborts without reporting
+// the error. Outside PG_TRY, PostgreSQL itself promotes an ERROR to FATAL
+// when there is no exception stack, and the error is reported before the
+// backend exits. patchReThrow rewrites the test in pg_re_throw,
+//
+//	i32.load; i32.eqz; br_if 0   // PG_exception_stack == NULL
+//
+// to one that always holds,
+//
+//	drop; i32.const 1; nop; br_if 0
+//
+// so that every error takes that path. If blob has no such function, it
+// is returned unchanged.
+func patchReThrow(blob []byte) []byte {
+	start, end, err := funcBody(blob, "pg_re_throw")
+	if err != nil {
+		return blob
+	}
+	i := bytes.Index(blob[start:end], []byte{0x28, 0x02, 0x00, 0x45, 0x0d, 0x00})
+	if i < 0 {
+		return blob
+	}
+	patched := bytes.Clone(blob)
+	copy(patched[start+i:], []byte{0x1a, 0x41, 0x01, 0x01})
+	return patched
+}
+
+// funcBody returns the offsets in blob of the body of the function with
+// the given name in the module's name section.
+func funcBody(blob []byte, name string) (start, end int, err error) {
+	if !bytes.HasPrefix(blob, wasmMagic) {
+		return 0, 0, errors.New("not a WASM binary")
+	}
+	var imported uint32
+	index := -1
+	var code []byte
+	r := &wasmReader{b: blob, off: len(wasmMagic)}
+	for r.off < len(r.b) && r.err == nil {
+		id := r.byte()
+		size := r.u32()
+		end := r.off + int(size)
+		if r.err != nil || end > len(r.b) {
+			return 0, 0, errors.New("truncated WASM section")
+		}
+		switch id {
+		case 0: // custom
+			if r.name() == "name" {
+				index = r.funcIndex(end, name)
+			}
+		case 2: // import
+			imported = r.importedFuncs()
+		case 10: // code
+			code = r.b[:end]
+			start = r.off
+		}
+		r.off = end
+	}
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+	if index < int(imported) || code == nil {
+		return 0, 0, fmt.Errorf("WASM binary has no function %s", name)
+	}
+
+	r = &wasmReader{b: code, off: start}
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		size := int(r.u32())
+		if i == uint32(index)-imported {
+			if r.off+size > len(code) {
+				break
+			}
+			return r.off, r.off + size, nil
+		}
+		r.off += size
+	}
+	return 0, 0, errors.New("malformed WASM code section")
+}
+
+// name reads a length-prefixed name.
+func (r *wasmReader) name() string {
+	n := int(r.u32())
+	if r.err != nil || r.off+n > len(r.b) {
+		r.err = errors.New("unexpected end of WASM binary")
+		return ""
+	}
+	s := string(r.b[r.off : r.off+n])
+	r.off += n
+	return s
+}
+
+// funcIndex reads the name section up to end and returns the index of
+// the function with the given name, or -1.
+func (r *wasmReader) funcIndex(end int, name string) int {
+	for r.off < end && r.err == nil {
+		id := r.byte()
+		size := r.u32()
+		next := r.off + int(size)
+		if id == 1 { // function names
+			n := r.u32()
+			for i := uint32(0); i < n && r.err == nil; i++ {
+				index := r.u32()
+				if r.name() == name {
+					return int(index)
+				}
+			}
+		}
+		r.off = next
+	}
+	return -1
+}
+
+// importedFuncs reads the import section and returns the number of
+// functions imported, which come first in the function index space.
+func (r *wasmReader) importedFuncs() uint32 {
+	var funcs uint32
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		r.name() // module
+		r.name() // field
+		switch kind := r.byte(); kind {
+		case 0: // function: type index
+			r.u32()
+			funcs++
+		case 1: // table: element type, limits
+			r.byte()
+			r.limits()
+		case 2: // memory: limits
+			r.limits()
+		case 3: // global: value type, mutability
+			r.byte()
+			r.byte()
+		default:
+			r.err = fmt.Errorf("unknown import kind %d", kind)
+		}
+	}
+	return funcs
+}
+
+// limits reads the limits of a table or memory.
+func (r *wasmReader) limits() {
+	if flags := r.byte(); flags&1 != 0 {
+		r.u32()
+		r.u32()
+	} else {
+		r.u32()
+	}
+}
+
+// wasmReader decodes the LEB128 encoded values in a WASM binary.
+type wasmReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.off >= len(r.b) {
+		r.err = errors.New("unexpected end of WASM binary")
+		return 0
+	}
+	c := r.b[r.off]
+	r.off++
+	return c
+}
+
+func (r *wasmReader) u32() uint32 {
+	var v uint32
+	for shift := 0; shift < 35; shift += 7 {
+		c := r.byte()
+		v |= uint32(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = errors.New("malformed LEB128 value")
+	return 0
+}
+
+func (r *wasmReader) s32() int32 {
+	var v int32
+	for shift := 0; shift < 35; shift += 7 {
+		c := r.byte()
+		v |= int32(c&0x7f) << shift
+		if c&0x80 == 0 {
+			if shift < 25 && c&0x40 != 0 {
+				v |= -1 << (shift + 7)
+			}
+			return v
+		}
+	}
+	r.err = errors.New("malformed LEB128 value")
+	return 0
+}
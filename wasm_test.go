@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInputBuffer(t *testing.T) {
+	blob, err := os.ReadFile("tmp/pglite/bin/postgres.wasi")
+	if err != nil {
+		t.Fatalf("read module: %v", err)
+	}
+	addr, size, err := inputBuffer(blob)
+	if err != nil {
+		t.Fatalf("inputBuffer: %v", err)
+	}
+	if addr != 1 || size != 4095 {
+		t.Errorf("got buffer at %d with size %d, want 1 and 4095", addr, size)
+	}
+}
+
+func TestDataStartRejectsGarbage(t *testing.T) {
+	if _, err := dataStart([]byte("not wasm")); err == nil {
+		t.Error("expected error for non-WASM input")
+	}
+	if _, err := dataStart(append(wasmMagic, 11, 0xff)); err == nil {
+		t.Error("expected error for truncated section")
+	}
+}
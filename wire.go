@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// After use_socketfile, the module reads frontend protocol messages from a
+// file in the data directory and writes the backend's replies to another.
+// Each file is written under a lock name and renamed into place.
+const (
+	sockIn     = "tmp/pglite/base/.s.PGSQL.5432.in"
+	sockLockIn = "tmp/pglite/base/.s.PGSQL.5432.lock.in"
+	sockOut    = "tmp/pglite/base/.s.PGSQL.5432.out"
+
+	// sockLockCopy is where COPY data is written before it is linked
+	// into place at sockOut; see feedCopy.
+	sockLockCopy = "tmp/pglite/base/.s.PGSQL.5432.lock.copy"
+)
+
+// maxMessageSize is the longest message the backend reads from the socket
+// file; longer ones are cut short.
+const maxMessageSize = 8192
+
+// maxQuerySize is the longest query, with its terminating NUL, that fits
+// in a Query message of maxMessageSize, after its type and length.
+const maxQuerySize = maxMessageSize - 5
+
+// Startup request codes sent in place of a protocol version.
+const (
+	protocolVersion3 = 196608
+	sslRequestCode   = 80877103
+	gssRequestCode   = 80877104
+	cancelCode       = 80877102
+)
+
+// Serve accepts connections on l and proxies the PostgreSQL frontend/backend
+// protocol between each client and the embedded instance, so unmodified
+// clients such as psql can connect.
+//
+// The instance has one backend session. Clients are served one at a time,
+// in the order they connect, and each sees the session state left by the
+// previous one. Authentication always succeeds. Serve returns when Accept
+// fails, for example after l is closed.
+func (p *PGLite) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		if err := p.serveConn(c); err != nil {
+			p.log.Errorf("serve %s: %v", c.RemoteAddr(), err)
+		}
+	}
+}
+
+// ServeTCP listens on the TCP address addr, such as "127.0.0.1:5432", and
+// serves clients there as Serve does, in the background. It returns the
+// address listened on, which gives the assigned port if addr's port is 0.
+//
+// Every client is trusted, whatever user and password it gives, so listen
+// only on addresses that untrusted hosts cannot reach, such as loopback.
+// The listener is closed when the instance is closed.
+func (p *PGLite) ServeTCP(addr string) (net.Addr, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p.listeners = append(p.listeners, l)
+	go p.Serve(l)
+	return l.Addr(), nil
+}
+
+// serveConn handles one client connection until it terminates.
+func (p *PGLite) serveConn(c net.Conn) error {
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	if err := p.startup(r, c); err != nil {
+		return err
+	}
+
+	var pending []byte
+	for {
+		typ, msg, err := readMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if typ == 'X' { // Terminate
+			return nil
+		}
+		pending = append(pending, msg...)
+
+		// Forward buffered messages once the client waits for a reply:
+		// after Query, Sync, Flush, FunctionCall, CopyDone and CopyFail.
+		switch typ {
+		case 'Q', 'S', 'H', 'F', 'c', 'f':
+		default:
+			continue
+		}
+		reply, err := p.roundTrip(pending)
+		pending = pending[:0]
+		if err != nil {
+			return err
+		}
+		if _, err := c.Write(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// startup reads the client's startup message and completes the handshake
+// on behalf of the backend, whose session is already established.
+func (p *PGLite) startup(r *bufio.Reader, w io.Writer) error {
+	for {
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return err
+		}
+		if n < 8 || n > 10000 {
+			return fmt.Errorf("invalid startup message length %d", n)
+		}
+		body := make([]byte, n-4)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+
+		switch code := binary.BigEndian.Uint32(body); code {
+		case sslRequestCode, gssRequestCode:
+			if _, err := w.Write([]byte{'N'}); err != nil {
+				return err
+			}
+		case cancelCode:
+			return fmt.Errorf("cancel request: %w", ErrNotSupported)
+		case protocolVersion3:
+			return writeHandshake(w)
+		default:
+			return fmt.Errorf("unsupported protocol version %d", code)
+		}
+	}
+}
+
+// writeHandshake sends AuthenticationOk, the parameters clients expect and
+// ReadyForQuery.
+func writeHandshake(w io.Writer) error {
+	var b []byte
+	b = appendMessage(b, 'R', []byte{0, 0, 0, 0})
+	for _, kv := range [][2]string{
+		{"server_version", "16.4"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		b = appendMessage(b, 'S', []byte(kv[0]+"\x00"+kv[1]+"\x00"))
+	}
+	b = appendMessage(b, 'K', make([]byte, 8))
+	b = appendMessage(b, 'Z', []byte{'I'})
+	_, err := w.Write(b)
+	return err
+}
+
+// readMessage reads a typed protocol message and returns its type and raw
+// bytes, including the header.
+func readMessage(r *bufio.Reader) (byte, []byte, error) {
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n < 4 {
+		return 0, nil, fmt.Errorf("invalid message length %d", n)
+	}
+	msg := make([]byte, 1+n)
+	copy(msg, hdr)
+	if _, err := io.ReadFull(r, msg[5:]); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], msg, nil
+}
+
+// appendMessage appends a protocol message with the given type and body.
+func appendMessage(b []byte, typ byte, body []byte) []byte {
+	b = append(b, typ)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)+4))
+	return append(b, body...)
+}
+
+// roundTrip hands frontend messages to the backend through the socket files
+// and returns its reply.
+func (p *PGLite) roundTrip(msgs []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.resume(); err != nil {
+		return nil, err
+	}
+	if p.closed || p.mod.IsClosed() {
+		return nil, ErrClosed
+	}
+
+	var reply []byte
+	err := p.exchange(p.ctx, msgs, nil, func(_ byte, msg []byte) error {
+		reply = append(reply, msg...)
+		return nil
+	})
+	return reply, err
+}
+
+// exchange hands frontend messages to the backend through the socket files
+// and calls fn with each message of the reply, header included. The
+// backend reads a single message per call, so each is sent in turn. If fn
+// returns an error, the rest of the reply is discarded. The caller must
+// hold p.mu. Notifications in the reply are also delivered to the
+// channels returned by Listen. If copyIn is not nil, it is fed to the
+// backend as COPY data while the first message runs; see call.
+//
+// When a message fails, the backend is restarted, as the failure leaves it
+// unusable; see send. The messages up to the next Sync are then dropped,
+// as PostgreSQL drops them after an error, and a ReadyForQuery stands in
+// for the one the Sync would have brought.
+func (p *PGLite) exchange(ctx context.Context, msgs []byte, copyIn io.Reader, fn func(typ byte, msg []byte) error) error {
+	defer func() { p.touched = time.Now() }()
+	deliver := func(typ byte, msg []byte) error {
+		if typ == 'A' { // NotificationResponse
+			p.notify(msg[5:])
+		}
+		return fn(typ, msg)
+	}
+	r := bufio.NewReader(bytes.NewReader(msgs))
+	for {
+		typ, msg, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		failed, err := p.send(ctx, msg, copyIn, deliver)
+		copyIn = nil
+		if err != nil {
+			return err
+		}
+		if !failed {
+			continue
+		}
+		if p.compiled == nil {
+			// The instance is still starting, and the start fails instead.
+			return nil
+		}
+
+		if err := p.restart(p.opts); err != nil {
+			p.callFailed(err)
+			p.close(p.ctx)
+			return fmt.Errorf("restart after error: %w", err)
+		}
+		for typ != 'Q' && typ != 'S' {
+			if typ, _, err = readMessage(r); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		if err := fn('Z', appendMessage(nil, 'Z', []byte{'I'})); err != nil {
+			return err
+		}
+	}
+}
+
+// send hands one message to the backend and passes its reply to fn,
+// feeding it copyIn as COPY data if that is not nil. It reports whether
+// the message failed with an error that left the backend unusable.
+//
+// The backend has no exception stack to unwind to, so PostgreSQL promotes
+// each ERROR to FATAL, and the exit that follows traps. The next call
+// writes out the reply with the error, but the session is left with the
+// failed statement's resources still held, and is restarted.
+func (p *PGLite) send(ctx context.Context, msg []byte, copyIn io.Reader, fn func(typ byte, msg []byte) error) (bool, error) {
+	if len(msg) > maxMessageSize {
+		return false, fmt.Errorf("%w: message of %d bytes, limit %d", ErrQueryTooLarge, len(msg), maxMessageSize)
+	}
+	lockIn := filepath.Join(p.dataDir, sockLockIn)
+	if err := os.WriteFile(lockIn, msg, 0600); err != nil {
+		return false, err
+	}
+	if err := os.Rename(lockIn, filepath.Join(p.dataDir, sockIn)); err != nil {
+		return false, err
+	}
+
+	trap := p.call(ctx, copyIn)
+	var exitErr *sys.ExitError
+	if trap != nil && errors.As(trap, &exitErr) {
+		p.callFailed(trap)
+		return false, trap
+	}
+	if trap != nil {
+		if err := p.call(ctx, nil); err != nil {
+			p.callFailed(err)
+			return false, err
+		}
+	}
+
+	// After an error, only the reply up to the ErrorResponse belongs to
+	// the failed message.
+	reported := false
+	err := p.readReply(func(typ byte, msg []byte) error {
+		if reported {
+			return nil
+		}
+		if typ == 'E' && trap != nil {
+			reported = true
+		}
+		return fn(typ, msg)
+	})
+	if err == nil && trap != nil && !reported {
+		// The trap was not an error report but a crash.
+		p.callFailed(trap)
+		return false, trap
+	}
+	return reported, err
+}
+
+// call runs the backend once. If copyIn is not nil, it is fed to the
+// backend as COPY data for the length of the call; see feedCopy.
+func (p *PGLite) call(ctx context.Context, copyIn io.Reader) error {
+	// An empty input buffer makes the module read from the socket file
+	// rather than rerun the last query.
+	if !p.mod.Memory().WriteByte(p.inAddr, 0) {
+		return fmt.Errorf("write query: address %d out of range", p.inAddr)
+	}
+	if copyIn != nil {
+		defer p.feedCopy(copyIn)()
+	}
+	_, err := p.mod.ExportedFunction("interactive_one").Call(ctx)
+	return err
+}
+
+// readReply passes each message in the backend's reply file to fn and
+// removes the file.
+func (p *PGLite) readReply(fn func(typ byte, msg []byte) error) error {
+	out := filepath.Join(p.dataDir, sockOut)
+	f, err := os.Open(out)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		typ, msg, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read reply: %w", err)
+		}
+		if err := fn(typ, msg); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "pglite.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	go testPG.Serve(l)
+
+	c, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	params := []byte("user\x00postgres\x00database\x00postgres\x00\x00")
+	startup := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	startup = binary.BigEndian.AppendUint32(startup, protocolVersion3)
+	if _, err := c.Write(append(startup, params...)); err != nil {
+		t.Fatalf("write startup: %v", err)
+	}
+	readUntilReady(t, r)
+
+	if _, err := c.Write(appendMessage(nil, 'Q', []byte("SELECT 40 + 2;\x00"))); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	msgs := readUntilReady(t, r)
+	var found bool
+	for _, m := range msgs {
+		if m[0] == 'D' && bytes.HasSuffix(m, []byte("42")) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no DataRow with 42 in reply: %q", msgs)
+	}
+
+	c.Write(appendMessage(nil, 'X', nil))
+}
+
+func TestServeTCP(t *testing.T) {
+	pg, err := NewPGLiteWithOptions(context.Background(), io.Discard, io.Discard, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewPGLiteWithOptions: %v", err)
+	}
+	addr, err := pg.ServeTCP("127.0.0.1:0")
+	if err != nil {
+		pg.Close()
+		t.Fatalf("ServeTCP: %v", err)
+	}
+	if addr.(*net.TCPAddr).Port == 0 {
+		t.Errorf("no port assigned: %v", addr)
+	}
+
+	c, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		pg.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	r := bufio.NewReader(c)
+	startup := binary.BigEndian.AppendUint32(nil, 9)
+	startup = binary.BigEndian.AppendUint32(startup, protocolVersion3)
+	if _, err := c.Write(append(startup, 0)); err != nil {
+		t.Fatalf("write startup: %v", err)
+	}
+	readUntilReady(t, r)
+	if _, err := c.Write(appendMessage(nil, 'Q', []byte("SELECT 1;\x00"))); err != nil {
+		t.Fatalf("write query: %v", err)
+	}
+	readUntilReady(t, r)
+	c.Close()
+
+	pg.Close()
+	if c, err := net.Dial("tcp", addr.String()); err == nil {
+		c.Close()
+		t.Error("listener still open after Close")
+	}
+}
+
+// readUntilReady reads backend messages up to and including ReadyForQuery.
+func readUntilReady(t *testing.T, r *bufio.Reader) [][]byte {
+	t.Helper()
+	var msgs [][]byte
+	for {
+		typ, msg, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("read message: %v", err)
+		}
+		msgs = append(msgs, msg)
+		if typ == 'Z' {
+			return msgs
+		}
+	}
+}